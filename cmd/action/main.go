@@ -6,7 +6,12 @@ import (
 )
 
 func main() {
-	err := actions.RunAction()
+	config, err := actions.ConfigFromAction()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = actions.RunAction(config)
 	if err != nil {
 		logrus.Fatal(err)
 	}
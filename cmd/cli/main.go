@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source/cli"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	config, err := cli.ParseArgs(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = cli.Run(config)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
@@ -4,20 +4,102 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/rajatjindal/krew-release-bot/pkg/audit"
+	"github.com/rajatjindal/krew-release-bot/pkg/poller"
 	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/actionwebhook"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/githubwebhook"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/oidc"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
 	ghToken := os.Getenv("GH_TOKEN")
+	var policy *releaser.PolicyFile
+	policyFile := os.Getenv("PLUGIN_POLICY_FILE")
+	if policyFile != "" {
+		var err error
+		policy, err = releaser.NewPolicyFile(policyFile)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
 	releaser := releaser.New(ghToken)
+	releaser.PolicyFile = policy
 
 	s := &http.Server{
 		Addr:           fmt.Sprintf(":%d", 8080),
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	http.HandleFunc("/github-action-webhook", releaser.HandleActionWebhook)
+	hook, err := actionwebhook.NewGithubActions(ghToken)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	//authenticate releases from github actions using the OIDC token of the workflow
+	if audience := os.Getenv("OIDC_AUDIENCE"); audience != "" {
+		var keys oidc.KeySet = oidc.NewRemoteKeySet(oidc.GithubJWKSURL)
+		if jwksFile := os.Getenv("OIDC_JWKS_FILE"); jwksFile != "" {
+			keys, err = oidc.LoadKeySet(jwksFile)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+		} else if jwksURL := os.Getenv("OIDC_JWKS_URL"); jwksURL != "" {
+			keys = oidc.NewRemoteKeySet(jwksURL)
+		}
+
+		hook.Verifier = oidc.NewVerifier(audience, keys)
+	}
+
+	http.HandleFunc("/github-action-webhook", releaser.HandleActionWebhook(hook))
+	http.HandleFunc("/jobs/", releaser.HandleJob())
+
+	//polls releases of plugins, which do not use the action, to release them automatically
+	if interval := os.Getenv("POLL_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			logrus.Fatalf("invalid POLL_INTERVAL %q. error: %v", interval, err)
+		}
+
+		go poller.New(releaser).Run(d, make(chan struct{}))
+	}
+
+	//audits that the uri and sha256 of plugins in krew-index still match
+	if interval := os.Getenv("AUDIT_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			logrus.Fatalf("invalid AUDIT_INTERVAL %q. error: %v", interval, err)
+		}
+
+		auditor := audit.New(ghToken, releaser.UpstreamKrewIndexRepoCloneURL)
+		auditor.OpenIssues = os.Getenv("AUDIT_OPEN_ISSUES") == "true"
+
+		http.HandleFunc("/audit-report", auditor.HandleReport())
+		go auditor.Run(d, make(chan struct{}))
+	}
+
+	//release events sent by github directly, for installations of the github app or by repo webhooks
+	secret, repoSecretsFile := os.Getenv("GITHUB_WEBHOOK_SECRET"), os.Getenv("GITHUB_WEBHOOK_REPO_SECRETS_FILE")
+	if secret != "" || repoSecretsFile != "" {
+		repoSecrets := map[string]string{}
+		if repoSecretsFile != "" {
+			repoSecrets, err = githubwebhook.LoadRepoSecrets(repoSecretsFile)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+		}
+
+		releaseHook, err := githubwebhook.NewGithubWebhook(secret, repoSecrets, ghToken)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		http.HandleFunc("/github-release-webhook", releaser.HandleReleaseEvent(releaseHook))
+	}
+
 	logrus.Fatal(s.ListenAndServe())
 }
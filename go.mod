@@ -18,6 +18,7 @@ require (
 	gopkg.in/h2non/gock.v1 v1.0.15
 	gopkg.in/src-d/go-git.v4 v4.13.1
 	gopkg.in/yaml.v2 v2.2.7 // indirect
-	k8s.io/apimachinery v0.17.0 // indirect
+	k8s.io/apimachinery v0.17.0
 	sigs.k8s.io/krew v0.3.3
+	sigs.k8s.io/yaml v1.1.0
 )
@@ -0,0 +1,144 @@
+package assets
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+//archiveEntry is a file, dir or link in the archive
+type archiveEntry struct {
+	Name string
+	Mode os.FileMode
+	Size int64
+
+	//Linkname is the target of symlinks and hard links
+	Linkname string
+
+	//Open opens the content of the entry. For tar.gz archives
+	//it is only valid until the next entry is visited
+	Open func() (io.ReadCloser, error)
+}
+
+//walkArchive calls fn for each entry of the tar.gz or zip archive.
+//The format is detected from the content, as downloaded files are not named after the asset
+func walkArchive(file string, fn func(entry *archiveEntry) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(f, magic)
+	if err != nil {
+		return fmt.Errorf("failed to detect archive format. error: %v", err)
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return walkTarGz(f, fn)
+	case bytes.Equal(magic, []byte{'P', 'K', 0x03, 0x04}):
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		return walkZip(f, info.Size(), fn)
+	}
+
+	return fmt.Errorf("neither a tar.gz nor a zip archive")
+}
+
+func walkTarGz(r io.Reader, fn func(entry *archiveEntry) error) error {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		entry := &archiveEntry{
+			Name:     hdr.Name,
+			Mode:     hdr.FileInfo().Mode(),
+			Size:     hdr.Size,
+			Linkname: hdr.Linkname,
+			Open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(tr), nil
+			},
+		}
+
+		err = fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(r io.ReaderAt, size int64, fn func(entry *archiveEntry) error) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		f := f
+		entry := &archiveEntry{
+			Name: f.Name,
+			Mode: f.Mode(),
+			Size: int64(f.UncompressedSize64),
+			Open: f.Open,
+		}
+
+		if entry.Mode&os.ModeSymlink != 0 {
+			entry.Linkname, err = readZipLink(f)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//readZipLink reads the target of a symlink, which zip stores as the file content
+func readZipLink(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	target, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return "", err
+	}
+
+	return string(target), nil
+}
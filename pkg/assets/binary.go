@@ -0,0 +1,256 @@
+package assets
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+//maxBinarySize is the max size of the bin read in memory to detect its os and arch
+const maxBinarySize = 512 << 20
+
+var (
+	elfArch = map[elf.Machine]string{
+		elf.EM_X86_64:  "amd64",
+		elf.EM_386:     "386",
+		elf.EM_AARCH64: "arm64",
+		elf.EM_ARM:     "arm",
+		elf.EM_PPC64:   "ppc64",
+		elf.EM_S390:    "s390x",
+	}
+
+	machoArch = map[macho.Cpu]string{
+		macho.CpuAmd64: "amd64",
+		macho.Cpu386:   "386",
+		macho.CpuArm64: "arm64",
+		macho.CpuArm:   "arm",
+	}
+
+	peArch = map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+		pe.IMAGE_FILE_MACHINE_I386:  "386",
+		0xaa64:                      "arm64",
+		pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+	}
+)
+
+//verifyBinary verifies that the bin of the platform in the archive is built for the
+//os and arch in the platform selector, and returns the detected platform
+func verifyBinary(file string, platform index.Platform) (string, error) {
+	expectedOS, expectedArch := "", ""
+	if platform.Selector != nil {
+		expectedOS = platform.Selector.MatchLabels["os"]
+		expectedArch = platform.Selector.MatchLabels["arch"]
+	}
+
+	data, err := readBinary(file, platform)
+	if err != nil {
+		return "", err
+	}
+
+	goos, goarch, err := detectPlatform(data)
+	if err == errNotBinary {
+		return "a script, skipping os/arch verification", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	found := fmt.Sprintf("%s/%s", goos, goarch)
+	if (expectedOS != "" && expectedOS != goos) || (expectedArch != "" && !source.Contains(strings.Split(goarch, ","), expectedArch)) {
+		return "", fmt.Errorf("bin %s is built for %s", platform.Bin, found)
+	}
+
+	return found, nil
+}
+
+//readBinary reads the content of the bin of the platform from the archive
+func readBinary(file string, platform index.Platform) ([]byte, error) {
+	paths := []string{}
+	err := walkArchive(file, func(entry *archiveEntry) error {
+		paths = append(paths, entry.Name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := resolveBin(paths, platform.Files, platform.Bin)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = walkArchive(file, func(entry *archiveEntry) error {
+		if data != nil || cleanPath(entry.Name) != src {
+			return nil
+		}
+
+		if entry.Size > maxBinarySize {
+			return fmt.Errorf("bin %s is bigger than %d bytes", platform.Bin, maxBinarySize)
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		data, err = ioutil.ReadAll(io.LimitReader(rc, maxBinarySize+1))
+		if err != nil {
+			return err
+		}
+
+		if int64(len(data)) > maxBinarySize {
+			return fmt.Errorf("bin %s is bigger than %d bytes", platform.Bin, maxBinarySize)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("bin %s (%s in archive) is not a file", platform.Bin, src)
+	}
+
+	return data, nil
+}
+
+//resolveBin applies the file operations the same way krew does on install,
+//and returns the path in the archive that ends up as bin
+func resolveBin(entries []string, files []index.FileOperation, bin string) (string, error) {
+	if files == nil {
+		files = []index.FileOperation{{From: "*", To: "."}}
+	}
+
+	all := map[string]bool{}
+	for _, entry := range entries {
+		for p := cleanPath(entry); p != "." && p != "/"; p = path.Dir(p) {
+			all[p] = true
+		}
+	}
+
+	sorted := []string{}
+	for p := range all {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	installed := map[string]string{}
+	move := func(from, to string) {
+		installed[to] = from
+		for _, p := range sorted {
+			if strings.HasPrefix(p, from+"/") {
+				installed[path.Join(to, strings.TrimPrefix(p, from+"/"))] = p
+			}
+		}
+	}
+
+	for _, op := range files {
+		from := cleanPath(op.From)
+		to := path.Clean(filepath.ToSlash(op.To))
+
+		if all[from] {
+			dst := to
+			if to == "." {
+				dst = path.Base(from)
+			}
+
+			move(from, dst)
+			continue
+		}
+
+		for _, p := range sorted {
+			if ok, _ := path.Match(from, p); ok {
+				move(p, path.Join(to, path.Base(p)))
+			}
+		}
+	}
+
+	src, ok := installed[cleanPath(bin)]
+	if !ok {
+		return "", fmt.Errorf("bin %s not found in archive after applying files operations", bin)
+	}
+
+	return src, nil
+}
+
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+var errNotBinary = errors.New("not a binary")
+
+//detectPlatform detects the os and arch of a ELF, Mach-O or PE binary
+func detectPlatform(data []byte) (string, string, error) {
+	r := bytes.NewReader(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("#!")):
+		return "", "", errNotBinary
+
+	case bytes.HasPrefix(data, []byte(elf.ELFMAG)):
+		f, err := elf.NewFile(r)
+		if err != nil {
+			return "", "", err
+		}
+
+		goos := "linux"
+		if f.OSABI == elf.ELFOSABI_FREEBSD {
+			goos = "freebsd"
+		}
+
+		goarch := elfArch[f.Machine]
+		if f.Machine == elf.EM_PPC64 && f.ByteOrder == binary.LittleEndian {
+			goarch = "ppc64le"
+		}
+
+		return goos, lookupArch(goarch, f.Machine), nil
+
+	case bytes.HasPrefix(data, []byte("MZ")):
+		f, err := pe.NewFile(r)
+		if err != nil {
+			return "", "", err
+		}
+
+		return "windows", lookupArch(peArch[f.Machine], f.Machine), nil
+	}
+
+	if fat, err := macho.NewFatFile(r); err == nil {
+		arches := []string{}
+		for _, arch := range fat.Arches {
+			arches = append(arches, lookupArch(machoArch[arch.Cpu], arch.Cpu))
+		}
+
+		return "darwin", strings.Join(arches, ","), nil
+	}
+
+	if f, err := macho.NewFile(r); err == nil {
+		return "darwin", lookupArch(machoArch[f.Cpu], f.Cpu), nil
+	}
+
+	return "", "", fmt.Errorf("unknown binary format")
+}
+
+func lookupArch(arch string, machine interface{}) string {
+	if arch != "" {
+		return arch
+	}
+
+	return fmt.Sprintf("unknown(%v)", machine)
+}
@@ -0,0 +1,231 @@
+package assets
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestResolveBin(t *testing.T) {
+	testcases := []struct {
+		name          string
+		entries       []string
+		files         []index.FileOperation
+		bin           string
+		expectedSrc   string
+		expectedError string
+	}{
+		{
+			name:        "default files operation",
+			entries:     []string{"kubectl-whoami", "LICENSE"},
+			bin:         "kubectl-whoami",
+			expectedSrc: "kubectl-whoami",
+		},
+		{
+			name:        "binary in a wrapping directory",
+			entries:     []string{"whoami_linux_amd64/kubectl-whoami", "whoami_linux_amd64/LICENSE"},
+			files:       []index.FileOperation{{From: "whoami_linux_amd64/*", To: "."}},
+			bin:         "kubectl-whoami",
+			expectedSrc: "whoami_linux_amd64/kubectl-whoami",
+		},
+		{
+			name:        "binary renamed by files operation",
+			entries:     []string{"./bin/whoami-linux", "./LICENSE"},
+			files:       []index.FileOperation{{From: "bin/whoami-linux", To: "kubectl-whoami"}},
+			bin:         "kubectl-whoami",
+			expectedSrc: "bin/whoami-linux",
+		},
+		{
+			name:        "directory moved into a subdirectory",
+			entries:     []string{"bin/kubectl-whoami"},
+			files:       []index.FileOperation{{From: "bin", To: "tools"}},
+			bin:         "tools/kubectl-whoami",
+			expectedSrc: "bin/kubectl-whoami",
+		},
+		{
+			name:          "bin not in archive",
+			entries:       []string{"LICENSE"},
+			files:         []index.FileOperation{{From: "*", To: "."}},
+			bin:           "kubectl-whoami",
+			expectedError: "bin kubectl-whoami not found in archive after applying files operations",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := resolveBin(tc.entries, tc.files, tc.bin)
+			assert.Equal(t, tc.expectedSrc, src)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestDetectPlatform(t *testing.T) {
+	testcases := []struct {
+		name          string
+		data          []byte
+		expectedOS    string
+		expectedArch  string
+		expectedError string
+	}{
+		{
+			name:         "linux amd64 elf",
+			data:         elfBinary(elf.EM_X86_64),
+			expectedOS:   "linux",
+			expectedArch: "amd64",
+		},
+		{
+			name:         "linux arm64 elf",
+			data:         elfBinary(elf.EM_AARCH64),
+			expectedOS:   "linux",
+			expectedArch: "arm64",
+		},
+		{
+			name:         "linux ppc64le elf",
+			data:         elfBinaryWithByteOrder(elf.EM_PPC64, binary.LittleEndian),
+			expectedOS:   "linux",
+			expectedArch: "ppc64le",
+		},
+		{
+			name:         "linux ppc64 elf",
+			data:         elfBinaryWithByteOrder(elf.EM_PPC64, binary.BigEndian),
+			expectedOS:   "linux",
+			expectedArch: "ppc64",
+		},
+		{
+			name:         "darwin amd64 mach-o",
+			data:         machoBinary(macho.CpuAmd64),
+			expectedOS:   "darwin",
+			expectedArch: "amd64",
+		},
+		{
+			name:         "windows 386 pe",
+			data:         peBinary(pe.IMAGE_FILE_MACHINE_I386),
+			expectedOS:   "windows",
+			expectedArch: "386",
+		},
+		{
+			name:          "shell script",
+			data:          []byte("#!/bin/bash\necho whoami"),
+			expectedError: "not a binary",
+		},
+		{
+			name:          "text file",
+			data:          []byte("whoami"),
+			expectedError: "unknown binary format",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			goos, goarch, err := detectPlatform(tc.data)
+			assert.Equal(t, tc.expectedOS, goos)
+			assert.Equal(t, tc.expectedArch, goarch)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func newPlatform(goos, goarch, uri, bin string) index.Platform {
+	return index.Platform{
+		URI:      uri,
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": goos, "arch": goarch}},
+		Bin:      bin,
+	}
+}
+
+func elfBinary(machine elf.Machine) []byte {
+	return elfBinaryWithByteOrder(machine, binary.LittleEndian)
+}
+
+func elfBinaryWithByteOrder(machine elf.Machine, order binary.ByteOrder) []byte {
+	hdr := elf.Header64{
+		Type:    uint16(elf.ET_EXEC),
+		Machine: uint16(machine),
+		Version: uint32(elf.EV_CURRENT),
+		Ehsize:  64,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	if order == binary.BigEndian {
+		hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2MSB)
+	}
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, order, hdr)
+	return buf.Bytes()
+}
+
+func machoBinary(cpu macho.Cpu) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, macho.FileHeader{
+		Magic: macho.Magic64,
+		Cpu:   cpu,
+		Type:  macho.TypeExec,
+	})
+	//reserved field of the 64 bit header
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	return buf.Bytes()
+}
+
+func peBinary(machine uint16) []byte {
+	dos := make([]byte, 64)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 64)
+
+	buf := bytes.NewBuffer(dos)
+	buf.WriteString("PE\x00\x00")
+	binary.Write(buf, binary.LittleEndian, pe.FileHeader{Machine: machine})
+	//padding, as the pe parser reads ahead of the file header
+	buf.Write(make([]byte, 256))
+	return buf.Bytes()
+}
+
+func tarGz(files map[string][]byte) []byte {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write(content)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func zipArchive(files map[string][]byte) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write(content)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func assertError(t *testing.T, expectedError string, err error) {
+	if expectedError == "" {
+		assert.Nil(t, err)
+	}
+
+	if expectedError != "" {
+		assert.NotNil(t, err)
+		if err != nil {
+			assert.Equal(t, expectedError, err.Error())
+		}
+	}
+}
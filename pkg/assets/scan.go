@@ -0,0 +1,129 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	//maxCompressionRatio is the max ratio of uncompressed to compressed size of an archive
+	maxCompressionRatio = 100
+
+	//archives smaller than this when uncompressed are not checked for compression ratio
+	minCompressionCheckSize = 10 << 20
+
+	//maxUncompressedSize is the max total size of the files in an archive
+	maxUncompressedSize = 1 << 30
+)
+
+//errSizeLimit stops walking the archive once the files are bigger than maxUncompressedSize
+var errSizeLimit = errors.New("uncompressed size limit reached")
+
+//scanArchive inspects the entries of the archive, and returns the findings
+//that make it unsafe to extract
+func scanArchive(file string) ([]string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []string{}
+	var uncompressed int64
+	err = walkArchive(file, func(entry *archiveEntry) error {
+		findings = append(findings, scanEntry(entry)...)
+		if !entry.Mode.IsRegular() {
+			return nil
+		}
+
+		//the bytes are counted when decompressing, as sizes in the archive headers can be forged
+		n, err := decompressedSize(entry, maxUncompressedSize-uncompressed+1)
+		uncompressed += n
+		if err != nil {
+			return err
+		}
+
+		if uncompressed > maxUncompressedSize {
+			return errSizeLimit
+		}
+
+		return nil
+	})
+	if err == errSizeLimit {
+		findings = append(findings, fmt.Sprintf("uncompressed size is more than the limit of %d bytes", maxUncompressedSize))
+		return findings, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if uncompressed > minCompressionCheckSize && info.Size() > 0 && uncompressed/info.Size() > maxCompressionRatio {
+		findings = append(findings, fmt.Sprintf("compression ratio %d is more than the limit of %d, possible decompression bomb", uncompressed/info.Size(), maxCompressionRatio))
+	}
+
+	return findings, nil
+}
+
+//decompressedSize reads the content of the entry, up to limit bytes, and returns the bytes read
+func decompressedSize(entry *archiveEntry, limit int64) (int64, error) {
+	rc, err := entry.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	return io.Copy(ioutil.Discard, io.LimitReader(rc, limit))
+}
+
+func scanEntry(entry *archiveEntry) []string {
+	findings := []string{}
+	name := toSlash(entry.Name)
+
+	if isAbs(name) {
+		findings = append(findings, fmt.Sprintf("entry %q has an absolute path", entry.Name))
+	} else if escapesRoot(name) {
+		findings = append(findings, fmt.Sprintf("entry %q has path traversal", entry.Name))
+	}
+
+	if entry.Linkname != "" {
+		target := toSlash(entry.Linkname)
+		if entry.Mode&os.ModeSymlink != 0 {
+			//symlinks are relative to the directory of the link
+			target = path.Join(path.Dir(name), target)
+		}
+
+		if isAbs(toSlash(entry.Linkname)) || escapesRoot(target) {
+			findings = append(findings, fmt.Sprintf("entry %q links to %q outside of the extraction root", entry.Name, entry.Linkname))
+		}
+	}
+
+	if entry.Mode&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		findings = append(findings, fmt.Sprintf("entry %q has setuid or setgid bit set", entry.Name))
+	}
+
+	if entry.Mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket) != 0 {
+		findings = append(findings, fmt.Sprintf("entry %q is a device, pipe or socket", entry.Name))
+	}
+
+	return findings
+}
+
+//toSlash converts both / and \ separators, as archives may be created on any os
+func toSlash(name string) string {
+	return strings.ReplaceAll(name, "\\", "/")
+}
+
+func isAbs(name string) bool {
+	return strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':')
+}
+
+//escapesRoot checks if the path goes above the root it is relative to
+func escapesRoot(name string) bool {
+	cleaned := path.Clean(name)
+	return cleaned == ".." || strings.HasPrefix(cleaned, "../")
+}
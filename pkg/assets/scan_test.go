@@ -0,0 +1,140 @@
+package assets
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanEntry(t *testing.T) {
+	testcases := []struct {
+		name             string
+		entry            *archiveEntry
+		expectedFindings []string
+	}{
+		{
+			name:             "regular file",
+			entry:            &archiveEntry{Name: "bin/kubectl-whoami", Mode: 0755},
+			expectedFindings: []string{},
+		},
+		{
+			name:             "absolute path",
+			entry:            &archiveEntry{Name: "/usr/local/bin/kubectl-whoami", Mode: 0755},
+			expectedFindings: []string{`entry "/usr/local/bin/kubectl-whoami" has an absolute path`},
+		},
+		{
+			name:             "windows absolute path",
+			entry:            &archiveEntry{Name: `C:\Windows\kubectl-whoami.exe`, Mode: 0755},
+			expectedFindings: []string{`entry "C:\\Windows\\kubectl-whoami.exe" has an absolute path`},
+		},
+		{
+			name:             "path traversal",
+			entry:            &archiveEntry{Name: "bin/../../kubectl-whoami", Mode: 0755},
+			expectedFindings: []string{`entry "bin/../../kubectl-whoami" has path traversal`},
+		},
+		{
+			name:             "path traversal with backslash",
+			entry:            &archiveEntry{Name: `..\kubectl-whoami.exe`, Mode: 0755},
+			expectedFindings: []string{`entry "..\\kubectl-whoami.exe" has path traversal`},
+		},
+		{
+			name:             "symlink inside root",
+			entry:            &archiveEntry{Name: "bin/kubectl-whoami", Mode: os.ModeSymlink | 0777, Linkname: "../kubectl-whoami-linux"},
+			expectedFindings: []string{},
+		},
+		{
+			name:             "symlink outside root",
+			entry:            &archiveEntry{Name: "bin/kubectl-whoami", Mode: os.ModeSymlink | 0777, Linkname: "../../kubectl-whoami"},
+			expectedFindings: []string{`entry "bin/kubectl-whoami" links to "../../kubectl-whoami" outside of the extraction root`},
+		},
+		{
+			name:             "symlink to absolute path",
+			entry:            &archiveEntry{Name: "kubectl-whoami", Mode: os.ModeSymlink | 0777, Linkname: "/etc/passwd"},
+			expectedFindings: []string{`entry "kubectl-whoami" links to "/etc/passwd" outside of the extraction root`},
+		},
+		{
+			name:             "hard link outside root",
+			entry:            &archiveEntry{Name: "kubectl-whoami", Mode: 0755, Linkname: "../kubectl-whoami"},
+			expectedFindings: []string{`entry "kubectl-whoami" links to "../kubectl-whoami" outside of the extraction root`},
+		},
+		{
+			name:             "setuid binary",
+			entry:            &archiveEntry{Name: "kubectl-whoami", Mode: os.ModeSetuid | 0755},
+			expectedFindings: []string{`entry "kubectl-whoami" has setuid or setgid bit set`},
+		},
+		{
+			name:             "device file",
+			entry:            &archiveEntry{Name: "dev/null", Mode: os.ModeDevice | os.ModeCharDevice | 0666},
+			expectedFindings: []string{`entry "dev/null" is a device, pipe or socket`},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := scanEntry(tc.entry)
+			assert.Equal(t, tc.expectedFindings, findings)
+		})
+	}
+}
+
+func TestScanArchiveDecompressionBomb(t *testing.T) {
+	zeros := make([]byte, minCompressionCheckSize+1)
+	data := tarGz(map[string][]byte{"kubectl-whoami": zeros})
+
+	file, err := ioutil.TempFile("", "archive-")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	assert.Nil(t, err)
+	file.Close()
+
+	findings, err := scanArchive(file.Name())
+	assert.Nil(t, err)
+	assert.Len(t, findings, 1)
+	assert.Contains(t, findings[0], "possible decompression bomb")
+}
+
+func TestScanArchiveZipDecompressionBomb(t *testing.T) {
+	zeros := make([]byte, minCompressionCheckSize+1)
+	data := zipArchive(map[string][]byte{"kubectl-whoami.exe": zeros})
+
+	file, err := ioutil.TempFile("", "archive-")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	assert.Nil(t, err)
+	file.Close()
+
+	findings, err := scanArchive(file.Name())
+	assert.Nil(t, err)
+	assert.Len(t, findings, 1)
+	assert.Contains(t, findings[0], "possible decompression bomb")
+}
+
+func TestScanArchiveTarTypes(t *testing.T) {
+	data := tarWithHeaders([]*tar.Header{
+		{Name: "kubectl-whoami", Mode: 0755, Typeflag: tar.TypeReg},
+		{Name: "fifo", Mode: 0644, Typeflag: tar.TypeFifo},
+		{Name: "link", Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: "/usr/bin/kubectl"},
+	})
+
+	file, err := ioutil.TempFile("", "archive-")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	assert.Nil(t, err)
+	file.Close()
+
+	findings, err := scanArchive(file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`entry "fifo" is a device, pipe or socket`,
+		`entry "link" links to "/usr/bin/kubectl" outside of the extraction root`,
+	}, findings)
+}
@@ -0,0 +1,100 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+//Verify downloads the archive of each platform in the plugin manifest, checks that it matches the
+//sha256 in the manifest, scans it for unsafe entries, and verifies that its bin is built for the
+//os and arch in the platform selector
+func Verify(plugin index.Plugin) error {
+	failed := []string{}
+	for _, platform := range plugin.Spec.Platforms {
+		name := PlatformName(platform)
+		err := verifyPlatform(platform)
+		if err != nil {
+			logrus.Errorf("verification failed for %s (%s): %v", name, platform.URI, err)
+			failed = append(failed, fmt.Sprintf("%s: %s: %v", name, platform.URI, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("verification of release assets failed for %d platform(s):\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+
+	return nil
+}
+
+func verifyPlatform(platform index.Platform) error {
+	file, err := source.DownloadFileWithName(platform.URI, "archive")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(filepath.Dir(file))
+
+	//the downloaded file is the one published in krew-index, only if it matches the sha256
+	sha, err := fileSha256(file)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(sha, platform.Sha256) {
+		return fmt.Errorf("sha256 of the archive is %s, but %s in the manifest", sha, platform.Sha256)
+	}
+
+	findings, err := scanArchive(file)
+	if err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		for _, finding := range findings {
+			logrus.Errorf("%s: %s", platform.URI, finding)
+		}
+
+		return fmt.Errorf("unsafe archive: %s", strings.Join(findings, "; "))
+	}
+
+	found, err := verifyBinary(file, platform)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("verified %s for %s: bin %s is %s", platform.URI, PlatformName(platform), platform.Bin, found)
+	return nil
+}
+
+func fileSha256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//PlatformName is the os/arch in the selector of the platform
+func PlatformName(platform index.Platform) string {
+	if platform.Selector == nil {
+		return "<no selector>"
+	}
+
+	return fmt.Sprintf("%s/%s", platform.Selector.MatchLabels["os"], platform.Selector.MatchLabels["arch"])
+}
@@ -0,0 +1,118 @@
+package assets
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/hex"
+	"testing"
+
+	"gopkg.in/h2non/gock.v1"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestVerify(t *testing.T) {
+	testcases := []struct {
+		name          string
+		linux         []byte
+		windows       []byte
+		published     []byte
+		expectedError string
+	}{
+		{
+			name:    "all archives are safe and binaries match their platform",
+			linux:   tarGz(map[string][]byte{"kubectl-whoami": elfBinary(elf.EM_X86_64)}),
+			windows: zipArchive(map[string][]byte{"kubectl-whoami.exe": peBinary(pe.IMAGE_FILE_MACHINE_AMD64)}),
+		},
+		{
+			name:          "linux binary has wrong arch",
+			linux:         tarGz(map[string][]byte{"kubectl-whoami": elfBinary(elf.EM_AARCH64)}),
+			windows:       zipArchive(map[string][]byte{"kubectl-whoami.exe": peBinary(pe.IMAGE_FILE_MACHINE_AMD64)}),
+			expectedError: "verification of release assets failed for 1 platform(s):\nlinux/amd64: https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64.tar.gz: bin kubectl-whoami is built for linux/arm64",
+		},
+		{
+			name:          "darwin binary in windows archive",
+			linux:         tarGz(map[string][]byte{"kubectl-whoami": elfBinary(elf.EM_X86_64)}),
+			windows:       zipArchive(map[string][]byte{"kubectl-whoami.exe": machoBinary(macho.CpuAmd64)}),
+			expectedError: "verification of release assets failed for 1 platform(s):\nwindows/amd64: https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/windows-amd64.zip: bin kubectl-whoami.exe is built for darwin/amd64",
+		},
+		{
+			name: "archive has path traversal and setuid binary",
+			linux: tarWithHeaders([]*tar.Header{
+				{Name: "kubectl-whoami", Mode: 04755, Typeflag: tar.TypeReg},
+				{Name: "../../etc/profile.d/whoami.sh", Mode: 0644, Typeflag: tar.TypeReg},
+			}),
+			windows:       zipArchive(map[string][]byte{"kubectl-whoami.exe": peBinary(pe.IMAGE_FILE_MACHINE_AMD64)}),
+			expectedError: "verification of release assets failed for 1 platform(s):\nlinux/amd64: https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64.tar.gz: unsafe archive: entry \"kubectl-whoami\" has setuid or setgid bit set; entry \"../../etc/profile.d/whoami.sh\" has path traversal",
+		},
+		{
+			name:          "asset is not an archive",
+			linux:         []byte("linux-amd64"),
+			windows:       zipArchive(map[string][]byte{"kubectl-whoami.exe": peBinary(pe.IMAGE_FILE_MACHINE_AMD64)}),
+			expectedError: "verification of release assets failed for 1 platform(s):\nlinux/amd64: https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64.tar.gz: neither a tar.gz nor a zip archive",
+		},
+		{
+			name:          "archive is re-uploaded after it was hashed",
+			linux:         tarGz(map[string][]byte{"kubectl-whoami": elfBinary(elf.EM_X86_64)}),
+			windows:       zipArchive(map[string][]byte{"kubectl-whoami.exe": peBinary(pe.IMAGE_FILE_MACHINE_AMD64)}),
+			published:     []byte("linux-amd64"),
+			expectedError: "verification of release assets failed for 1 platform(s):\nlinux/amd64: https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64.tar.gz: sha256 of the archive is " + sha256Hex(tarGz(map[string][]byte{"kubectl-whoami": elfBinary(elf.EM_X86_64)})) + ", but " + sha256Hex([]byte("linux-amd64")) + " in the manifest",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gock.OffAll()
+			gock.DisableNetworking()
+			mockAsset("linux-amd64.tar.gz", tc.linux)
+			mockAsset("windows-amd64.zip", tc.windows)
+
+			published := tc.published
+			if published == nil {
+				published = tc.linux
+			}
+
+			linux := newPlatform("linux", "amd64", "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64.tar.gz", "kubectl-whoami")
+			linux.Sha256 = sha256Hex(published)
+			windows := newPlatform("windows", "amd64", "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/windows-amd64.zip", "kubectl-whoami.exe")
+			windows.Sha256 = sha256Hex(tc.windows)
+
+			plugin := index.Plugin{
+				Spec: index.PluginSpec{
+					Platforms: []index.Platform{linux, windows},
+				},
+			}
+
+			err := Verify(plugin)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func mockAsset(name string, content []byte) {
+	gock.New("https://github.com").
+		Get("/rajatjindal/kubectl-whoami/releases/download/v0.0.2/" + name).
+		Reply(200).
+		Body(bytes.NewReader(content))
+}
+
+func tarWithHeaders(headers []*tar.Header) []byte {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range headers {
+		tw.WriteHeader(hdr)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
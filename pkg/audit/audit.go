@@ -0,0 +1,350 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/rajatjindal/krew-release-bot/pkg/assets"
+	"github.com/rajatjindal/krew-release-bot/pkg/krew"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
+)
+
+//problems found when auditing the platforms of a plugin
+const (
+	ProblemSha256Mismatch = "sha256_mismatch"
+	ProblemNotFound       = "not_found"
+	ProblemDownloadFailed = "download_failed"
+)
+
+//Finding is a platform of a plugin in krew-index, which can no longer be installed
+type Finding struct {
+	Plugin   string `json:"plugin"`
+	Version  string `json:"version"`
+	Homepage string `json:"homepage"`
+	Platform string `json:"platform"`
+	URI      string `json:"uri"`
+	Problem  string `json:"problem"`
+
+	//Expected is the sha256 in krew-index, and Actual is the sha256 of the downloaded file
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+
+	//Error is the reason the download failed
+	Error string `json:"error,omitempty"`
+
+	//Issue is the url of the issue opened in the plugin repo, if any
+	Issue string `json:"issue,omitempty"`
+}
+
+//Report is the result of auditing krew-index
+type Report struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+
+	//Plugins and URIs are the number of plugins and unique uris checked
+	Plugins  int       `json:"plugins"`
+	URIs     int       `json:"uris"`
+	Findings []Finding `json:"findings"`
+}
+
+//Auditor re-downloads the uri of every platform of the plugins in krew-index, and checks
+//that it still matches the sha256, so that re-uploaded or deleted assets are noticed
+type Auditor struct {
+	client     *github.Client
+	httpClient *http.Client
+	cloneURL   string
+
+	//OpenIssues opens an issue in the plugin repo, listing the broken platforms
+	OpenIssues bool
+
+	mu     sync.Mutex
+	report *Report
+}
+
+//New returns the auditor for krew-index at cloneURL. The token is used to open issues
+func New(token, cloneURL string) *Auditor {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+
+	return &Auditor{
+		client: github.NewClient(oauth2.NewClient(context.TODO(), ts)),
+		httpClient: &http.Client{
+			Timeout: 5 * time.Minute,
+		},
+		cloneURL: cloneURL,
+	}
+}
+
+//Run audits every interval, until stop is closed
+func (a *Auditor) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := a.auditIndex()
+		if err != nil {
+			logrus.Errorf("auditing krew-index failed. error: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Auditor) auditIndex() error {
+	pluginsDir, cleanup, err := krew.CloneIndex(a.cloneURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	_, err = a.Audit(pluginsDir)
+	return err
+}
+
+//Audit checks the platforms of the plugins in the krew-index plugins dir, and returns the report.
+//It is also served by HandleReport until the next audit
+func (a *Auditor) Audit(pluginsDir string) (*Report, error) {
+	report := &Report{StartedAt: time.Now(), Findings: []Finding{}}
+
+	plugins, err := indexscanner.LoadPluginListFromFS(pluginsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	//platforms may share the uri e.g. same archive for all platforms, so the uri is downloaded
+	//once, and its sha256 is compared with the sha256 of every platform
+	checked := map[string]*download{}
+	for _, plugin := range plugins {
+		report.Plugins++
+
+		findings := []Finding{}
+		for _, platform := range plugin.Spec.Platforms {
+			d, ok := checked[platform.URI]
+			if !ok {
+				d = a.download(platform.URI)
+				checked[platform.URI] = d
+			}
+
+			finding := d.check(platform.Sha256)
+
+			if finding == nil {
+				continue
+			}
+
+			f := *finding
+			f.Plugin = plugin.GetName()
+			f.Version = plugin.Spec.Version
+			f.Homepage = plugin.Spec.Homepage
+			f.Platform = assets.PlatformName(platform)
+			findings = append(findings, f)
+		}
+
+		if len(findings) == 0 {
+			continue
+		}
+
+		logrus.Warnf("%d platforms of plugin %s %s in krew-index are broken", len(findings), plugin.GetName(), plugin.Spec.Version)
+		if a.OpenIssues {
+			a.openIssueForFindings(plugin, findings)
+		}
+
+		report.Findings = append(report.Findings, findings...)
+	}
+
+	report.URIs = len(checked)
+	report.FinishedAt = time.Now()
+	logrus.Infof("audited %d plugins with %d uris in krew-index, found %d broken platforms", report.Plugins, report.URIs, len(report.Findings))
+
+	a.mu.Lock()
+	a.report = report
+	a.mu.Unlock()
+
+	return report, nil
+}
+
+//download is the result of downloading a uri, i.e. its sha256, or the finding if it cannot be downloaded
+type download struct {
+	uri     string
+	sha256  string
+	finding *Finding
+}
+
+//download downloads the uri and computes its sha256
+func (a *Auditor) download(uri string) *download {
+	resp, err := a.httpClient.Get(uri)
+	if err != nil {
+		return &download{uri: uri, finding: &Finding{URI: uri, Problem: ProblemDownloadFailed, Error: err.Error()}}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &download{uri: uri, finding: &Finding{URI: uri, Problem: ProblemNotFound}}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &download{uri: uri, finding: &Finding{URI: uri, Problem: ProblemDownloadFailed, Error: fmt.Sprintf("expected status code %d got %d", http.StatusOK, resp.StatusCode)}}
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, resp.Body)
+	if err != nil {
+		return &download{uri: uri, finding: &Finding{URI: uri, Problem: ProblemDownloadFailed, Error: err.Error()}}
+	}
+
+	return &download{uri: uri, sha256: fmt.Sprintf("%x", h.Sum(nil))}
+}
+
+//check compares the sha256 of the download with the expected sha256 of a platform,
+//and returns the finding or nil if it matches
+func (d *download) check(expected string) *Finding {
+	if d.finding != nil {
+		return d.finding
+	}
+
+	if !strings.EqualFold(d.sha256, expected) {
+		return &Finding{URI: d.uri, Problem: ProblemSha256Mismatch, Expected: expected, Actual: d.sha256}
+	}
+
+	return nil
+}
+
+//openIssueForFindings opens an issue for the findings which are broken for sure, and sets the issue on them.
+//Downloads failing e.g. due to rate limits or outages of the host are only reported, as they may be transient
+func (a *Auditor) openIssueForFindings(plugin index.Plugin, findings []Finding) {
+	broken := []int{}
+	for i, f := range findings {
+		if f.Problem == ProblemSha256Mismatch || f.Problem == ProblemNotFound {
+			broken = append(broken, i)
+		}
+	}
+
+	if len(broken) == 0 {
+		return
+	}
+
+	issueFindings := []Finding{}
+	for _, i := range broken {
+		issueFindings = append(issueFindings, findings[i])
+	}
+
+	issue, err := a.openIssue(plugin, issueFindings)
+	if err != nil {
+		logrus.Warnf("opening issue for plugin %s failed. error: %v", plugin.GetName(), err)
+	}
+
+	for _, i := range broken {
+		findings[i].Issue = issue
+	}
+}
+
+//openIssue opens an issue in the github repo of the plugin, unless one is already open
+func (a *Auditor) openIssue(plugin index.Plugin, findings []Finding) (string, error) {
+	owner, repo, ok := krew.HomepageRepo(plugin.Spec.Homepage)
+	if !ok {
+		return "", fmt.Errorf("homepage %q is not a github repo", plugin.Spec.Homepage)
+	}
+
+	title := fmt.Sprintf("Release assets of %s %s in krew-index are broken", plugin.GetName(), plugin.Spec.Version)
+
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		issues, resp, err := a.client.Issues.ListByRepo(context.TODO(), owner, repo, opts)
+		if err != nil {
+			return "", err
+		}
+
+		for _, issue := range issues {
+			if issue.GetTitle() == title {
+				return issue.GetHTMLURL(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	issue, _, err := a.client.Issues.Create(context.TODO(), owner, repo, &github.IssueRequest{
+		Title: github.String(title),
+		Body:  github.String(issueBody(plugin, findings)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("opened issue %s for plugin %s", issue.GetHTMLURL(), plugin.GetName())
+	return issue.GetHTMLURL(), nil
+}
+
+func issueBody(plugin index.Plugin, findings []Finding) string {
+	lines := []string{
+		fmt.Sprintf("hey %s maintainers,", plugin.GetName()),
+		"",
+		fmt.Sprintf("I am [krew-release-bot](https://github.com/rajatjindal/krew-release-bot). Version %s of %s in [krew-index](https://github.com/kubernetes-sigs/krew-index) cannot be installed on these platforms, as the release assets were deleted or re-uploaded after it was published:", plugin.Spec.Version, plugin.GetName()),
+		"",
+		"| Platform | URI | Problem |",
+		"|----------|-----|---------|",
+	}
+
+	for _, f := range findings {
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s |", f.Platform, f.URI, describe(f)))
+	}
+
+	lines = append(lines,
+		"",
+		"Please restore the assets, or release a new version to update krew-index.",
+		"",
+		"Thanks,",
+		"[krew-release-bot](https://github.com/rajatjindal/krew-release-bot)",
+	)
+
+	return strings.Join(lines, "\n")
+}
+
+func describe(f Finding) string {
+	switch f.Problem {
+	case ProblemNotFound:
+		return "not found"
+	case ProblemSha256Mismatch:
+		return fmt.Sprintf("sha256 is `%s`, expected `%s`", f.Actual, f.Expected)
+	}
+
+	return fmt.Sprintf("download failed: %s", f.Error)
+}
+
+//HandleReport returns the handler serving the report of the last audit as json
+func (a *Auditor) HandleReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		report := a.report
+		a.mu.Unlock()
+
+		if report == nil {
+			http.Error(w, "audit not done yet", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("content-type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
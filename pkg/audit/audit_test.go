@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const fooSha256 = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func mockAssets() {
+	//healthy plugin has same uri for all platforms, which is downloaded once
+	gock.New("https://github.com").
+		Get("/foo-bar/kubectl-healthy/releases/download/v0.0.1/v0.0.1.tar.gz").
+		Times(1).
+		Reply(200).
+		BodyString("foo")
+
+	gock.New("https://github.com").
+		Get("/foo-bar/kubectl-reuploaded/releases/download/v0.0.1/v0.0.1.tar.gz").
+		Reply(200).
+		BodyString("bar")
+
+	gock.New("https://github.com").
+		Get("/foo-bar/kubectl-deleted/releases/download/v0.0.1/v0.0.1.tar.gz").
+		Reply(404)
+}
+
+func newTestAuditor() *Auditor {
+	return &Auditor{
+		client:     github.NewClient(nil),
+		httpClient: &http.Client{},
+	}
+}
+
+func TestAudit(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+	mockAssets()
+
+	a := newTestAuditor()
+	report, err := a.Audit("data/plugins")
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+
+	assert.Equal(t, 3, report.Plugins)
+	assert.Equal(t, 3, report.URIs)
+	assert.Equal(t, []Finding{
+		{
+			Plugin:   "deleted",
+			Version:  "v0.0.1",
+			Homepage: "https://github.com/foo-bar/kubectl-deleted",
+			Platform: "linux/amd64",
+			URI:      "https://github.com/foo-bar/kubectl-deleted/releases/download/v0.0.1/v0.0.1.tar.gz",
+			Problem:  ProblemNotFound,
+		},
+		{
+			Plugin:   "reuploaded",
+			Version:  "v0.0.1",
+			Homepage: "https://github.com/foo-bar/kubectl-reuploaded",
+			Platform: "linux/amd64",
+			URI:      "https://github.com/foo-bar/kubectl-reuploaded/releases/download/v0.0.1/v0.0.1.tar.gz",
+			Problem:  ProblemSha256Mismatch,
+			Expected: fooSha256,
+			Actual:   "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9",
+		},
+	}, report.Findings)
+}
+
+func TestAuditSharedURIWithDifferentSha256(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	gock.New("https://github.com").
+		Get("/foo-bar/kubectl-shared-uri/releases/download/v0.0.1/v0.0.1.tar.gz").
+		Times(1).
+		Reply(200).
+		BodyString("foo")
+
+	a := newTestAuditor()
+	report, err := a.Audit("data/shared-uri")
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+
+	assert.Equal(t, 1, report.URIs)
+	assert.Equal(t, []Finding{
+		{
+			Plugin:   "shared-uri",
+			Version:  "v0.0.1",
+			Homepage: "https://github.com/foo-bar/kubectl-shared-uri",
+			Platform: "darwin/amd64",
+			URI:      "https://github.com/foo-bar/kubectl-shared-uri/releases/download/v0.0.1/v0.0.1.tar.gz",
+			Problem:  ProblemSha256Mismatch,
+			Expected: "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9",
+			Actual:   fooSha256,
+		},
+	}, report.Findings)
+}
+
+func TestAuditOpenIssues(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+	mockAssets()
+
+	//issue is already open for deleted plugin
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-deleted/issues").
+		MatchParam("state", "open").
+		Reply(200).
+		JSON(`[{"title": "Release assets of deleted v0.0.1 in krew-index are broken", "html_url": "https://github.com/foo-bar/kubectl-deleted/issues/5"}]`)
+
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-reuploaded/issues").
+		MatchParam("state", "open").
+		Reply(200).
+		JSON(`[{"title": "some other issue", "html_url": "https://github.com/foo-bar/kubectl-reuploaded/issues/1"}]`)
+	gock.New("https://api.github.com").
+		Post("/repos/foo-bar/kubectl-reuploaded/issues").
+		BodyString(`.*"title":"Release assets of reuploaded v0.0.1 in krew-index are broken".*sha256 is.*`).
+		Reply(201).
+		JSON(`{"html_url": "https://github.com/foo-bar/kubectl-reuploaded/issues/2"}`)
+
+	a := newTestAuditor()
+	a.OpenIssues = true
+	report, err := a.Audit("data/plugins")
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+
+	assert.Len(t, report.Findings, 2)
+	assert.Equal(t, "https://github.com/foo-bar/kubectl-deleted/issues/5", report.Findings[0].Issue)
+	assert.Equal(t, "https://github.com/foo-bar/kubectl-reuploaded/issues/2", report.Findings[1].Issue)
+}
+
+func TestAuditOpenIssuesForBrokenFindingsOnly(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	//github is down for one platform, and the asset of the other one is deleted
+	gock.New("https://github.com").
+		Get("/foo-bar/kubectl-unavailable/releases/download/v0.0.1/v0.0.1.tar.gz").
+		Reply(502)
+	gock.New("https://github.com").
+		Get("/foo-bar/kubectl-unavailable/releases/download/v0.0.1/v0.0.1-darwin.tar.gz").
+		Reply(404)
+
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-unavailable/issues").
+		MatchParam("state", "open").
+		Reply(200).
+		JSON(`[]`)
+	gock.New("https://api.github.com").
+		Post("/repos/foo-bar/kubectl-unavailable/issues").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+
+			//only the deleted asset is listed in the issue
+			return strings.Contains(string(body), "v0.0.1-darwin.tar.gz") && !strings.Contains(string(body), "download failed"), nil
+		}).
+		Reply(201).
+		JSON(`{"html_url": "https://github.com/foo-bar/kubectl-unavailable/issues/1"}`)
+
+	a := newTestAuditor()
+	a.OpenIssues = true
+	report, err := a.Audit("data/unavailable")
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+
+	assert.Len(t, report.Findings, 2)
+	for _, f := range report.Findings {
+		switch f.Problem {
+		case ProblemDownloadFailed:
+			assert.Equal(t, "", f.Issue)
+		case ProblemNotFound:
+			assert.Equal(t, "https://github.com/foo-bar/kubectl-unavailable/issues/1", f.Issue)
+		default:
+			t.Errorf("unexpected problem %q", f.Problem)
+		}
+	}
+
+	//no issue is opened when downloads failed for all platforms
+	gock.New("https://github.com").
+		Get("/foo-bar/kubectl-unavailable/releases/download/v0.0.1/.*").
+		Times(2).
+		Reply(502)
+
+	report, err = a.Audit("data/unavailable")
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Len(t, report.Findings, 2)
+	assert.Equal(t, "", report.Findings[0].Issue)
+	assert.Equal(t, "", report.Findings[1].Issue)
+}
+
+func TestHandleReport(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	a := newTestAuditor()
+
+	w := httptest.NewRecorder()
+	a.HandleReport()(w, httptest.NewRequest(http.MethodGet, "/audit-report", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockAssets()
+	_, err := a.Audit("data/plugins")
+	assert.Nil(t, err)
+
+	w = httptest.NewRecorder()
+	a.HandleReport()(w, httptest.NewRequest(http.MethodGet, "/audit-report", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("content-type"))
+
+	report := &Report{}
+	err = json.Unmarshal(w.Body.Bytes(), report)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Plugins)
+	assert.Len(t, report.Findings, 2)
+}
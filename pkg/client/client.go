@@ -0,0 +1,295 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/sirupsen/logrus"
+)
+
+//DefaultWebhookURL is the url of the hosted krew-release-bot webhook
+const DefaultWebhookURL = "https://krew-release-bot.rajatjindal.com/github-action-webhook"
+
+//ReleaseRequest is the request to release a new version of the plugin
+type ReleaseRequest = source.ReleaseRequest
+
+//ReleaseResponse is the response from the webhook
+type ReleaseResponse = source.ReleaseResponse
+
+//ResponseError is the error returned when the webhook fails to release the plugin
+type ResponseError = source.ResponseError
+
+//Client submits release requests to krew-release-bot webhook
+type Client struct {
+	webhookURL   string
+	httpClient   *http.Client
+	headers      http.Header
+	retries      int
+	retryDelay   time.Duration
+	pollInterval time.Duration
+}
+
+//Option configures the client
+type Option func(*Client)
+
+//New returns the client for the webhook at webhookURL. It defaults to DefaultWebhookURL when empty
+func New(webhookURL string, opts ...Option) *Client {
+	if webhookURL == "" {
+		webhookURL = DefaultWebhookURL
+	}
+
+	c := &Client{
+		webhookURL: webhookURL,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		headers:      http.Header{},
+		retries:      4,
+		retryDelay:   2 * time.Second,
+		pollInterval: 5 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+//WithHTTPClient sets the http client used to send the requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+//WithBearerToken authenticates the requests using a bearer token e.g. the OIDC token of the workflow,
+//which is verified by the webhook when OIDC verification is enabled
+func WithBearerToken(token string) Option {
+	return WithHeader("authorization", fmt.Sprintf("Bearer %s", token))
+}
+
+//WithHeader sets a header on every request
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Set(key, value)
+	}
+}
+
+//WithRetries sets the number of times a request failing with a retryable error is sent again,
+//and the time to wait before the first retry. The delay doubles with every retry
+func WithRetries(retries int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryDelay = delay
+	}
+}
+
+//WithPollInterval sets the time to wait between checking the status of a job
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = interval
+	}
+}
+
+//Submit sends the release request to the webhook, and waits for the PR to be opened.
+//Requests failing with a retryable error are sent again with the same idempotency key,
+//so that only one PR is opened
+func (c *Client) Submit(ctx context.Context, request *ReleaseRequest) (*ReleaseResponse, error) {
+	return c.submit(ctx, request, false)
+}
+
+//SubmitAsync sends the release request to the webhook, which releases the plugin in the background.
+//The returned response has the JobID to poll using Job or Wait
+func (c *Client) SubmitAsync(ctx context.Context, request *ReleaseRequest) (*ReleaseResponse, error) {
+	return c.submit(ctx, request, true)
+}
+
+//Job gets the status of the job
+func (c *Client) Job(ctx context.Context, id string) (*ReleaseResponse, error) {
+	return c.withRetries(ctx, func() (*ReleaseResponse, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.jobURL(id), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.do(req)
+	})
+}
+
+//Wait polls the job until it is done, and returns the response with the url of the PR
+func (c *Client) Wait(ctx context.Context, id string) (*ReleaseResponse, error) {
+	for {
+		response, err := c.Job(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.Status != source.StatusPending {
+			return response, nil
+		}
+
+		err = sleep(ctx, c.pollInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) submit(ctx context.Context, request *ReleaseRequest, async bool) (*ReleaseResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	key := source.IdempotencyKey(request)
+	return c.withRetries(ctx, func() (*ReleaseResponse, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.webhookURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set(source.IdempotencyKeyHeader, key)
+		if async {
+			req.Header.Set(source.PreferHeader, source.PreferRespondAsync)
+		}
+
+		return c.do(req)
+	})
+}
+
+func (c *Client) withRetries(ctx context.Context, send func() (*ReleaseResponse, error)) (*ReleaseResponse, error) {
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		response, err := send()
+		if err == nil {
+			return response, nil
+		}
+
+		if !IsRetryable(err) || attempt >= c.retries {
+			return nil, err
+		}
+
+		logrus.Warnf("attempt %d of %d failed, retrying in %s. error: %v", attempt+1, c.retries+1, delay, err)
+		err = sleep(ctx, delay)
+		if err != nil {
+			return nil, err
+		}
+
+		delay *= 2
+	}
+}
+
+//PRURL matches the url of the PR in responses of the webhook
+var PRURL = regexp.MustCompile(`https://github\.com/[^/\s"]+/[^/\s"]+/pull/[0-9]+`)
+
+//do sends the request, and returns the response or the error in it
+func (c *Client) do(req *http.Request) (*ReleaseResponse, error) {
+	for key := range c.headers {
+		req.Header.Set(key, c.headers.Get(key))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ReleaseResponse{}
+	err = json.Unmarshal(respBody, response)
+	if err != nil || response.APIVersion == "" {
+		//older versions of the webhook respond with plain text, and proxies in front of it e.g. with html
+		if resp.StatusCode != http.StatusOK {
+			return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		}
+
+		return &ReleaseResponse{
+			Status: source.StatusSubmitted,
+			PRURL:  PRURL.FindString(string(respBody)),
+		}, nil
+	}
+
+	if response.Error != nil {
+		if retryableStatus(resp.StatusCode) {
+			response.Error.Retryable = true
+		}
+
+		return nil, response.Error
+	}
+
+	switch response.Status {
+	case source.StatusSubmitted, source.StatusPending:
+		return response, nil
+	}
+
+	return nil, fmt.Errorf("unexpected status %q in response. status code: %d", response.Status, resp.StatusCode)
+}
+
+//jobURL is the url of the job, relative to the webhook url
+func (c *Client) jobURL(id string) string {
+	u, err := url.Parse(c.webhookURL)
+	if err != nil {
+		return c.webhookURL
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/github-action-webhook") + "/jobs/" + url.PathEscape(id)
+	return u.String()
+}
+
+//StatusError is the error returned when the response has an unexpected status code, and no error in the body
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("expected status code %d got %d. body: %s", http.StatusOK, e.StatusCode, e.Body)
+}
+
+//IsRetryable checks if the request failing with err may succeed if sent again.
+//Network errors, e.g. timeouts, and 5xx and 429 responses are retryable as the idempotency key
+//prevents opening another PR
+func IsRetryable(err error) bool {
+	switch e := err.(type) {
+	case *ResponseError:
+		return e.Retryable
+	case *StatusError:
+		return retryableStatus(e.StatusCode)
+	case *url.Error:
+		return true
+	}
+
+	return false
+}
+
+//retryableStatus checks if the status code is of a temporary failure, e.g. of the webhook or a proxy in front of it
+func retryableStatus(code int) bool {
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
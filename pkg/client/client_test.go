@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSubmit(t *testing.T) {
+	request := &ReleaseRequest{TagName: "v0.0.2", PluginOwner: "foo-bar", PluginRepo: "my-awesome-plugin"}
+
+	testcases := []struct {
+		name          string
+		opts          []Option
+		setupMocks    func()
+		expectedPR    string
+		expectedError string
+	}{
+		{
+			name: "pr submitted with auth and idempotency key",
+			opts: []Option{WithBearerToken("some-token")},
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					MatchHeader("authorization", "Bearer some-token").
+					MatchHeader("Idempotency-Key", source.IdempotencyKey(request)).
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "bearer token",
+			opts: []Option{WithBearerToken("some-token")},
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					MatchHeader("authorization", "Bearer some-token").
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "retryable error, succeeds on retry",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(502).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "upstream_error", "message": "github is down", "retryable": true}}`)
+
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "html error page of a proxy, succeeds on retry",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(502).
+					BodyString("<html><head><title>502 Bad Gateway</title></head><body>bad gateway</body></html>")
+
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "rate limited, succeeds on retry",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(429).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "rate_limited", "message": "too many requests", "retryable": false}}`)
+
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "html error page, retries exhausted",
+			opts: []Option{WithRetries(1, time.Millisecond)},
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Times(2).
+					Reply(502).
+					BodyString("<html><body>bad gateway</body></html>")
+			},
+			expectedError: "expected status code 200 got 502. body: <html><body>bad gateway</body></html>",
+		},
+		{
+			name: "plain text error which is not retryable",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(400).
+					BodyString("bad request")
+			},
+			expectedError: "expected status code 200 got 400. body: bad request",
+		},
+		{
+			name: "retries disabled",
+			opts: []Option{WithRetries(0, time.Millisecond)},
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(502).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "upstream_error", "message": "github is down", "retryable": true}}`)
+			},
+			expectedError: "github is down (code: upstream_error)",
+		},
+		{
+			name: "error which is not retryable",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(403).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "ownership_mismatch", "message": "homepage does not match", "retryable": false}}`)
+			},
+			expectedError: "homepage does not match (code: ownership_mismatch)",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			tc.setupMocks()
+
+			opts := append([]Option{WithRetries(4, time.Millisecond)}, tc.opts...)
+			response, err := New("", opts...).Submit(context.TODO(), request)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedPR, response.PRURL)
+			}
+
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func TestSubmitAsyncAndWait(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	gock.New("https://bot.example.com").
+		Post("/github-action-webhook").
+		MatchHeader("Prefer", "respond-async").
+		Reply(202).
+		JSON(`{"apiVersion": "v1", "status": "pending", "job_id": "abc"}`)
+
+	gock.New("https://bot.example.com").
+		Get("/jobs/abc").
+		Times(2).
+		Reply(200).
+		JSON(`{"apiVersion": "v1", "status": "pending", "job_id": "abc"}`)
+
+	gock.New("https://bot.example.com").
+		Get("/jobs/abc").
+		Reply(200).
+		JSON(`{"apiVersion": "v1", "status": "submitted", "job_id": "abc", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+
+	c := New("https://bot.example.com/github-action-webhook", WithPollInterval(time.Millisecond))
+
+	response, err := c.SubmitAsync(context.TODO(), &ReleaseRequest{TagName: "v0.0.2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", response.JobID)
+
+	response, err = c.Wait(context.TODO(), response.JobID)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.com/kubernetes-sigs/krew-index/pull/26", response.PRURL)
+	assert.True(t, gock.IsDone())
+}
+
+func TestWaitCancelled(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	gock.New("https://bot.example.com").
+		Get("/jobs/abc").
+		Persist().
+		Reply(200).
+		JSON(`{"apiVersion": "v1", "status": "pending", "job_id": "abc"}`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := New("https://bot.example.com/github-action-webhook", WithPollInterval(time.Millisecond)).Wait(ctx, "abc")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,38 @@
+package krew
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	ugit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+//CloneIndex makes a shallow clone of krew-index, and returns the plugins dir in it
+//with the func to remove the clone when done
+func CloneIndex(cloneURL string) (string, func(), error) {
+	tempdir, err := ioutil.TempDir("", "krew-index-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		os.RemoveAll(tempdir)
+	}
+
+	logrus.Infof("cloning %s", cloneURL)
+	_, err = ugit.PlainClone(tempdir, false, &ugit.CloneOptions{
+		URL:           cloneURL,
+		ReferenceName: plumbing.Master,
+		SingleBranch:  true,
+		Depth:         1,
+	})
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return filepath.Join(tempdir, "plugins"), cleanup, nil
+}
@@ -2,6 +2,8 @@ package krew
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"sigs.k8s.io/krew/pkg/index/indexscanner"
@@ -19,7 +21,7 @@ func ValidateOwnership(file, expectedOwner string) error {
 		return err
 	}
 
-	if !strings.HasPrefix(plugin.Spec.Homepage, fmt.Sprintf("https://github.com/%s/", expectedOwner)) {
+	if !strings.HasPrefix(NormalizeHomepage(plugin.Spec.Homepage)+"/", fmt.Sprintf("https://github.com/%s/", strings.ToLower(expectedOwner))) {
 		return fmt.Errorf("plugin homepage %s does not have prefix %s", plugin.Spec.Homepage, fmt.Sprintf("https://github.com/%s/", expectedOwner))
 	}
 
@@ -50,3 +52,70 @@ func GetPluginName(file string) (string, error) {
 func PluginFileName(name string) string {
 	return fmt.Sprintf("%s%s", name, ".yaml")
 }
+
+//FieldChange is a change to a protected field of the plugin manifest
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+//ProtectedFieldChanges returns the changes to fields of the plugin manifest that establish
+//the ownership of the plugin, i.e. name and homepage
+func ProtectedFieldChanges(existingFile, newFile string) ([]FieldChange, error) {
+	existing, err := indexscanner.ReadPluginFile(existingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := indexscanner.ReadPluginFile(newFile)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	if existing.GetName() != updated.GetName() {
+		changes = append(changes, FieldChange{Field: "name", Old: existing.GetName(), New: updated.GetName()})
+	}
+
+	if NormalizeHomepage(existing.Spec.Homepage) != NormalizeHomepage(updated.Spec.Homepage) {
+		changes = append(changes, FieldChange{Field: "homepage", Old: existing.Spec.Homepage, New: updated.Spec.Homepage})
+	}
+
+	return changes, nil
+}
+
+//NormalizeHomepage returns the homepage in a form that can be compared, so that trivial differences
+//like a trailing slash, http instead of https, or case of the host are not treated as a change.
+//Paths on github are compared ignoring case, as owner and repo names are case insensitive
+func NormalizeHomepage(homepage string) string {
+	u, err := url.Parse(strings.TrimSpace(homepage))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(homepage)
+	}
+
+	if u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+
+	u.Host = strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	if u.Host == "github.com" {
+		u.Path = strings.TrimSuffix(strings.ToLower(u.Path), ".git")
+	}
+
+	return u.String()
+}
+
+var homepageRepo = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/#?]+)`)
+
+//HomepageRepo returns the owner and repo of the plugin homepage, if it is a github repo
+func HomepageRepo(homepage string) (string, string, bool) {
+	matches := homepageRepo.FindStringSubmatch(homepage)
+	if matches == nil {
+		return "", "", false
+	}
+
+	return matches[1], matches[2], true
+}
@@ -121,3 +121,105 @@ func TestValidateOwnership(t *testing.T) {
 		})
 	}
 }
+
+func TestProtectedFieldChanges(t *testing.T) {
+	testcases := []struct {
+		name            string
+		existingFile    string
+		newFile         string
+		expectedChanges []FieldChange
+		expectedError   string
+	}{
+		{
+			name:            "no changes",
+			existingFile:    "data/valid-file.yaml",
+			newFile:         "data/valid-file.yaml",
+			expectedChanges: []FieldChange{},
+		},
+		{
+			name:         "homepage changed",
+			existingFile: "data/valid-file.yaml",
+			newFile:      "data/homepage-changed.yaml",
+			expectedChanges: []FieldChange{
+				{
+					Field: "homepage",
+					Old:   "https://github.com/rajatjindal/kubectl-whoami",
+					New:   "https://github.com/someone-else/kubectl-whoami",
+				},
+			},
+		},
+		{
+			name:            "homepage differs only in scheme, case and trailing slash",
+			existingFile:    "data/valid-file.yaml",
+			newFile:         "data/homepage-normalized.yaml",
+			expectedChanges: []FieldChange{},
+		},
+		{
+			name:          "new file does not exist",
+			existingFile:  "data/valid-file.yaml",
+			newFile:       "data/file-dont-exist.yaml",
+			expectedError: "open data/file-dont-exist.yaml: no such file or directory",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := ProtectedFieldChanges(tc.existingFile, tc.newFile)
+
+			if tc.expectedError != "" {
+				assert.NotNil(t, err)
+				if err != nil {
+					assert.Equal(t, tc.expectedError, err.Error())
+				}
+			}
+
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedChanges, changes)
+			}
+		})
+	}
+}
+
+func TestHomepageRepo(t *testing.T) {
+	testcases := []struct {
+		homepage string
+		owner    string
+		repo     string
+		ok       bool
+	}{
+		{homepage: "https://github.com/foo-bar/kubectl-foo", owner: "foo-bar", repo: "kubectl-foo", ok: true},
+		{homepage: "https://github.com/foo-bar/kubectl-foo/", owner: "foo-bar", repo: "kubectl-foo", ok: true},
+		{homepage: "https://github.com/foo-bar/kubectl-foo/tree/master/docs", owner: "foo-bar", repo: "kubectl-foo", ok: true},
+		{homepage: "https://github.com/foo-bar", ok: false},
+		{homepage: "https://foo-bar.dev/kubectl-foo", ok: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.homepage, func(t *testing.T) {
+			owner, repo, ok := HomepageRepo(tc.homepage)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.owner, owner)
+			assert.Equal(t, tc.repo, repo)
+		})
+	}
+}
+
+func TestNormalizeHomepage(t *testing.T) {
+	testcases := []struct {
+		homepage string
+		expected string
+	}{
+		{homepage: "https://github.com/foo-bar/kubectl-foo", expected: "https://github.com/foo-bar/kubectl-foo"},
+		{homepage: "http://www.GitHub.com/Foo-Bar/kubectl-foo/", expected: "https://github.com/foo-bar/kubectl-foo"},
+		{homepage: "https://github.com/foo-bar/kubectl-foo.git", expected: "https://github.com/foo-bar/kubectl-foo"},
+		{homepage: "https://Foo-Bar.dev/Docs/", expected: "https://foo-bar.dev/Docs"},
+		{homepage: "not a url", expected: "not a url"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.homepage, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NormalizeHomepage(tc.homepage))
+		})
+	}
+}
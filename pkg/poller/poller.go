@@ -0,0 +1,278 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/rajatjindal/krew-release-bot/pkg/krew"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/remote"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
+)
+
+//TemplateFile is the template plugin repos add to opt in to releases detected by the poller
+const TemplateFile = ".krew-auto-release.yaml"
+
+//failedRetryDelay is how long a tag which failed to release is skipped, so that
+//broken releases are not rendered and submitted again every interval
+const failedRetryDelay = 24 * time.Hour
+
+//errNotOptedIn is returned when rendering the release of a plugin repo without TemplateFile
+var errNotOptedIn = errors.New("plugin repo did not opt in")
+
+//Releaser renders and opens the PR for the release request, once for its plugin repo and tag
+type Releaser interface {
+	ReleaseOnce(request *source.ReleaseRequest, render func(request *source.ReleaseRequest) error) (string, error)
+}
+
+//Poller polls the github releases of the plugins in krew-index, for plugins which do not use the
+//action. New versions of plugins which opted in, by adding TemplateFile in their repo, are released
+type Poller struct {
+	releaser   Releaser
+	client     *github.Client
+	indexOwner string
+	indexRepo  string
+	cloneURL   string
+	policy     *releaser.PolicyFile
+
+	//submitted are the PRs opened by the poller, so that they are not opened again while listing
+	//open PRs lags behind. They are forgotten once the PRs are no longer open
+	submitted map[string]bool
+
+	//failed are the times releasing the PRs failed, so that they are retried only after failedRetryDelay.
+	//They are forgotten after failedRetryDelay
+	failed map[string]time.Time
+}
+
+//New returns the poller, which releases using the releaser and its token
+func New(r *releaser.Releaser) *Poller {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: r.Token})
+
+	return &Poller{
+		releaser:   r,
+		client:     github.NewClient(oauth2.NewClient(context.TODO(), ts)),
+		indexOwner: r.UpstreamKrewIndexRepoOwner,
+		indexRepo:  r.UpstreamKrewIndexRepo,
+		cloneURL:   r.UpstreamKrewIndexRepoCloneURL,
+		policy:     r.PolicyFile,
+		submitted:  map[string]bool{},
+		failed:     map[string]time.Time{},
+	}
+}
+
+//Run polls every interval, until stop is closed
+func (p *Poller) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := p.pollIndex()
+		if err != nil {
+			logrus.Errorf("polling releases of plugins in krew-index failed. error: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//pollIndex clones krew-index, and polls the releases of the plugins in it
+func (p *Poller) pollIndex() error {
+	pluginsDir, cleanup, err := krew.CloneIndex(p.cloneURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return p.Poll(pluginsDir)
+}
+
+//Poll checks the plugins in the krew-index plugins dir for newer releases, and releases them.
+//Failing to release one plugin does not stop releasing the others
+func (p *Poller) Poll(pluginsDir string) error {
+	plugins, err := indexscanner.LoadPluginListFromFS(pluginsDir)
+	if err != nil {
+		return err
+	}
+
+	openPRs, err := p.openPRTitles()
+	if err != nil {
+		return err
+	}
+
+	p.prune(openPRs)
+
+	for _, plugin := range plugins {
+		pr, err := p.checkPlugin(plugin, openPRs)
+		if err != nil {
+			logrus.Warnf("releasing new version of plugin %s failed. error: %v", plugin.GetName(), err)
+			continue
+		}
+
+		if pr != "" {
+			logrus.Infof("released new version of plugin %s in %s", plugin.GetName(), pr)
+		}
+	}
+
+	return nil
+}
+
+//checkPlugin releases the latest release of the plugin, if it is newer than the version in krew-index
+//and the plugin opted in. It returns the url of the PR, or empty if nothing was released
+func (p *Poller) checkPlugin(plugin index.Plugin, openPRs map[string]bool) (string, error) {
+	owner, repo, ok := krew.HomepageRepo(plugin.Spec.Homepage)
+	if !ok {
+		logrus.Debugf("skipping plugin %s as homepage %q is not a github repo", plugin.GetName(), plugin.Spec.Homepage)
+		return "", nil
+	}
+
+	release, resp, err := p.client.Repositories.GetLatestRelease(context.TODO(), owner, repo)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	newer, err := isNewer(release.GetTagName(), plugin.Spec.Version)
+	if err != nil {
+		logrus.Debugf("skipping plugin %s as versions cannot be compared. error: %v", plugin.GetName(), err)
+		return "", nil
+	}
+
+	if !newer {
+		return "", nil
+	}
+
+	request := &source.ReleaseRequest{
+		TagName:            release.GetTagName(),
+		PluginName:         plugin.GetName(),
+		PluginOwner:        owner,
+		PluginRepo:         repo,
+		PluginReleaseActor: release.GetAuthor().GetLogin(),
+		TemplateFile:       TemplateFile,
+	}
+
+	title := releaser.PRTitle(request)
+	if openPRs[title] || p.submitted[title] {
+		logrus.Debugf("skipping plugin %s as PR %q is already open", plugin.GetName(), title)
+		return "", nil
+	}
+
+	if failedAt, ok := p.failed[title]; ok && time.Since(failedAt) < failedRetryDelay {
+		logrus.Debugf("skipping plugin %s as releasing %s failed at %s", plugin.GetName(), request.TagName, failedAt)
+		return "", nil
+	}
+
+	pr, err := p.releaser.ReleaseOnce(request, func(request *source.ReleaseRequest) error {
+		return p.render(request, release, plugin)
+	})
+	if err == errNotOptedIn {
+		logrus.Debugf("skipping plugin %s as %s/%s did not opt in with %s", plugin.GetName(), owner, repo, TemplateFile)
+		return "", nil
+	}
+
+	if err != nil {
+		p.failed[title] = time.Now()
+		return "", err
+	}
+
+	delete(p.failed, title)
+	p.submitted[title] = true
+	return pr, nil
+}
+
+//prune forgets the submitted PRs which are no longer open, i.e. merged or closed,
+//and the failures which are retried anyways
+func (p *Poller) prune(openPRs map[string]bool) {
+	for title := range p.submitted {
+		if !openPRs[title] {
+			delete(p.submitted, title)
+		}
+	}
+
+	for title, failedAt := range p.failed {
+		if time.Since(failedAt) >= failedRetryDelay {
+			delete(p.failed, title)
+		}
+	}
+}
+
+//render renders the template of the release, for the plugin in krew-index
+func (p *Poller) render(request *source.ReleaseRequest, release *github.RepositoryRelease, plugin index.Plugin) error {
+	repoInfo, _, err := p.client.Repositories.Get(context.TODO(), request.PluginOwner, request.PluginRepo)
+	if err != nil {
+		return err
+	}
+
+	pluginName, manifest, err := remote.RenderTemplate(p.client, request, release, repoInfo, p.policy.Policy())
+	if remote.IsNotFound(err) {
+		return errNotOptedIn
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if pluginName != plugin.GetName() {
+		return fmt.Errorf("template %s in %s/%s is for plugin %s, expected %s", TemplateFile, request.PluginOwner, request.PluginRepo, pluginName, plugin.GetName())
+	}
+
+	request.ProcessedTemplate = manifest
+	logrus.Infof("releasing version %s of plugin %s, currently at %s in krew-index", request.TagName, plugin.GetName(), plugin.Spec.Version)
+	return nil
+}
+
+//openPRTitles returns the titles of open PRs in krew-index
+func (p *Poller) openPRTitles() (map[string]bool, error) {
+	titles := map[string]bool{}
+	opts := &github.PullRequestListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		prs, resp, err := p.client.PullRequests.List(context.TODO(), p.indexOwner, p.indexRepo, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range prs {
+			titles[pr.GetTitle()] = true
+		}
+
+		if resp.NextPage == 0 {
+			return titles, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+//isNewer checks if the tag is a newer semver than the version in krew-index
+func isNewer(tag, current string) (bool, error) {
+	latest, err := version.ParseSemantic(tag)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := version.ParseSemantic(current)
+	if err != nil {
+		return false, err
+	}
+
+	return existing.LessThan(latest), nil
+}
@@ -0,0 +1,223 @@
+package poller
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+type fakeReleaser struct {
+	requests []*source.ReleaseRequest
+	err      error
+}
+
+func (f *fakeReleaser) ReleaseOnce(request *source.ReleaseRequest, render func(request *source.ReleaseRequest) error) (string, error) {
+	err := render(request)
+	if err != nil {
+		return "", err
+	}
+
+	f.requests = append(f.requests, request)
+	if f.err != nil {
+		return "", f.err
+	}
+
+	return "https://github.com/kubernetes-sigs/krew-index/pull/26", nil
+}
+
+func mockLatestRelease(repo, tag string) {
+	gock.New("https://api.github.com").
+		Get(fmt.Sprintf("/repos/foo-bar/%s/releases/latest", repo)).
+		Reply(200).
+		JSON(fmt.Sprintf(`{"tag_name": %q, "author": {"login": "rajatjindal"}}`, tag))
+}
+
+func TestPoll(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	template, err := ioutil.ReadFile("data/template.yaml")
+	assert.Nil(t, err)
+
+	gock.New("https://api.github.com").
+		Get("/repos/kubernetes-sigs/krew-index/pulls").
+		MatchParam("state", "open").
+		Reply(200).
+		JSON(`[{"title": "release new version v0.0.2 of pr-open"}]`)
+
+	//opted-in
+	mockLatestRelease("kubectl-opted-in", "v0.0.2")
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-opted-in").
+		Reply(200).
+		JSON(`{"name": "kubectl-opted-in"}`)
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-opted-in/contents/.krew-auto-release.yaml").
+		MatchParam("ref", "v0.0.2").
+		Reply(200).
+		JSON(fmt.Sprintf(`{"type": "file", "encoding": "base64", "content": %q}`, base64.StdEncoding.EncodeToString(template)))
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-opted-in/commits/v0.0.2").
+		Reply(200).
+		BodyString("8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e")
+
+	//up-to-date
+	mockLatestRelease("kubectl-up-to-date", "v0.0.2")
+
+	//not-opted-in
+	mockLatestRelease("kubectl-not-opted-in", "v0.0.3")
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-not-opted-in").
+		Reply(200).
+		JSON(`{"name": "kubectl-not-opted-in"}`)
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-not-opted-in/contents/.krew-auto-release.yaml").
+		Reply(404).
+		JSON(`{"message": "Not Found"}`)
+
+	//pr-open
+	mockLatestRelease("kubectl-pr-open", "v0.0.2")
+
+	//no-releases
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-no-releases/releases/latest").
+		Reply(404).
+		JSON(`{"message": "Not Found"}`)
+
+	releaser := &fakeReleaser{}
+	p := &Poller{
+		releaser:   releaser,
+		client:     github.NewClient(nil),
+		indexOwner: "kubernetes-sigs",
+		indexRepo:  "krew-index",
+		submitted:  map[string]bool{},
+		failed:     map[string]time.Time{},
+	}
+
+	err = p.Poll("data/plugins")
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+
+	assert.Len(t, releaser.requests, 1)
+	request := releaser.requests[0]
+	assert.Equal(t, "opted-in", request.PluginName)
+	assert.Equal(t, "v0.0.2", request.TagName)
+	assert.Equal(t, "foo-bar", request.PluginOwner)
+	assert.Equal(t, "kubectl-opted-in", request.PluginRepo)
+	assert.Equal(t, "rajatjindal", request.PluginReleaseActor)
+	assert.Contains(t, string(request.ProcessedTemplate), "version: v0.0.2")
+	assert.True(t, p.submitted["release new version v0.0.2 of opted-in"])
+}
+
+func TestPollSkipsFailedTags(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	gock.New("https://api.github.com").
+		Get("/repos/kubernetes-sigs/krew-index/pulls").
+		MatchParam("state", "open").
+		Persist().
+		Reply(200).
+		JSON(`[]`)
+
+	//the plugin repo is broken, e.g. the template cannot be fetched
+	mockLatestRelease("kubectl-opted-in", "v0.0.2")
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-opted-in").
+		Reply(502).
+		JSON(`{"message": "Server Error"}`)
+
+	for _, repo := range []string{"kubectl-up-to-date", "kubectl-pr-open", "kubectl-not-opted-in", "kubectl-no-releases"} {
+		gock.New("https://api.github.com").
+			Get(fmt.Sprintf("/repos/foo-bar/%s/releases/latest", repo)).
+			Persist().
+			Reply(404).
+			JSON(`{"message": "Not Found"}`)
+	}
+
+	releaser := &fakeReleaser{}
+	p := &Poller{
+		releaser:   releaser,
+		client:     github.NewClient(nil),
+		indexOwner: "kubernetes-sigs",
+		indexRepo:  "krew-index",
+		submitted:  map[string]bool{},
+		failed:     map[string]time.Time{},
+	}
+
+	err := p.Poll("data/plugins")
+	assert.Nil(t, err)
+	failedAt, ok := p.failed["release new version v0.0.2 of opted-in"]
+	assert.True(t, ok)
+
+	//the failed tag is not rendered again on the next poll
+	mockLatestRelease("kubectl-opted-in", "v0.0.2")
+	err = p.Poll("data/plugins")
+	assert.Nil(t, err)
+	assert.Len(t, releaser.requests, 0)
+	assert.Equal(t, failedAt, p.failed["release new version v0.0.2 of opted-in"])
+
+	//until failedRetryDelay passed
+	p.failed["release new version v0.0.2 of opted-in"] = time.Now().Add(-failedRetryDelay)
+	mockLatestRelease("kubectl-opted-in", "v0.0.2")
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/kubectl-opted-in").
+		Reply(502).
+		JSON(`{"message": "Server Error"}`)
+
+	//and the tag is rendered again
+	err = p.Poll("data/plugins")
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), p.failed["release new version v0.0.2 of opted-in"], time.Minute)
+}
+
+func TestPrune(t *testing.T) {
+	p := &Poller{
+		submitted: map[string]bool{
+			"release new version v0.0.2 of pr-open":   true,
+			"release new version v0.0.2 of pr-merged": true,
+		},
+		failed: map[string]time.Time{
+			"release new version v0.0.2 of failed-recently": time.Now().Add(-time.Hour),
+			"release new version v0.0.1 of failed-long-ago": time.Now().Add(-failedRetryDelay),
+		},
+	}
+
+	p.prune(map[string]bool{"release new version v0.0.2 of pr-open": true})
+
+	assert.Equal(t, map[string]bool{"release new version v0.0.2 of pr-open": true}, p.submitted)
+	assert.Len(t, p.failed, 1)
+	assert.Contains(t, p.failed, "release new version v0.0.2 of failed-recently")
+}
+
+func TestIsNewer(t *testing.T) {
+	testcases := []struct {
+		tag      string
+		current  string
+		expected bool
+	}{
+		{tag: "v0.0.2", current: "v0.0.1", expected: true},
+		{tag: "v0.1.0", current: "v0.0.10", expected: true},
+		{tag: "v1.0.0", current: "v1.0.0-rc.1", expected: true},
+		{tag: "v0.0.1", current: "v0.0.1", expected: false},
+		{tag: "v0.0.1", current: "v0.0.2", expected: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(fmt.Sprintf("%s after %s", tc.tag, tc.current), func(t *testing.T) {
+			newer, err := isNewer(tc.tag, tc.current)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, newer)
+		})
+	}
+
+	_, err := isNewer("kubectl-foo/v0.0.2", "v0.0.1")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,107 @@
+package releaser
+
+import (
+	"sync"
+	"time"
+)
+
+//dedupeTTL is how long the PR opened for an idempotency key is remembered
+const dedupeTTL = 24 * time.Hour
+
+//failedJobTTL is how long a failure is kept, so that the status of the job can be checked.
+//Failures are not reused for new requests with the same key, which release again
+const failedJobTTL = 10 * time.Minute
+
+//dedupeStore makes sure that requests with the same idempotency key are released only once
+type dedupeStore struct {
+	mu      sync.Mutex
+	entries map[string]*dedupeEntry
+}
+
+type dedupeEntry struct {
+	done    chan struct{}
+	pr      string
+	err     error
+	expires time.Time
+}
+
+func newDedupeStore() *dedupeStore {
+	return &dedupeStore{
+		entries: map[string]*dedupeEntry{},
+	}
+}
+
+//do runs release once for the key. Requests with the same key, while it is running or after it
+//succeeded, get the same result. Failures are not remembered, so that a request sent again
+//after e.g. fixing the policy or re-uploading the assets runs release again
+func (s *dedupeStore) do(key string, release func() (string, error)) (string, error) {
+	entry := s.start(key, release)
+	<-entry.done
+	return entry.pr, entry.err
+}
+
+//start runs release for the key in the background, unless it is already running or done,
+//and returns the entry to wait for its result
+func (s *dedupeStore) start(key string, release func() (string, error)) *dedupeEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+
+	if entry, ok := s.entries[key]; ok && !entry.failed() {
+		return entry
+	}
+
+	entry := &dedupeEntry{done: make(chan struct{})}
+	s.entries[key] = entry
+
+	go func() {
+		pr, err := release()
+
+		s.mu.Lock()
+		entry.pr, entry.err = pr, err
+		entry.expires = time.Now().Add(dedupeTTL)
+		if err != nil {
+			entry.expires = time.Now().Add(failedJobTTL)
+		}
+		s.mu.Unlock()
+
+		close(entry.done)
+	}()
+
+	return entry
+}
+
+//get returns the entry for the key, to check the status of the release in the background
+func (s *dedupeStore) get(key string) (*dedupeEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+//expire removes the entries past their ttl. It must be called with the lock held
+func (s *dedupeStore) expire() {
+	now := time.Now()
+	for key, entry := range s.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+//finished checks if the release is done, without waiting for it
+func (e *dedupeEntry) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+//failed checks if the release is done, and failed
+func (e *dedupeEntry) failed() bool {
+	return e.finished() && e.err != nil
+}
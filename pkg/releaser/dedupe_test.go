@@ -0,0 +1,99 @@
+package releaser
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeStore(t *testing.T) {
+	t.Run("concurrent requests with same key are released once", func(t *testing.T) {
+		store := newDedupeStore()
+		calls := 0
+		started := make(chan struct{})
+		finish := make(chan struct{})
+
+		release := func() (string, error) {
+			calls++
+			close(started)
+			<-finish
+			return "https://github.com/kubernetes-sigs/krew-index/pull/26", nil
+		}
+
+		var wg sync.WaitGroup
+		results := make([]string, 2)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[0], _ = store.do("key", release)
+		}()
+
+		<-started
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[1], _ = store.do("key", release)
+		}()
+
+		close(finish)
+		wg.Wait()
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, []string{"https://github.com/kubernetes-sigs/krew-index/pull/26", "https://github.com/kubernetes-sigs/krew-index/pull/26"}, results)
+
+		pr, err := store.do("key", release)
+		assert.Nil(t, err)
+		assert.Equal(t, "https://github.com/kubernetes-sigs/krew-index/pull/26", pr)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retryable failures are released again", func(t *testing.T) {
+		store := newDedupeStore()
+		calls := 0
+		release := func() (string, error) {
+			calls++
+			if calls == 1 {
+				return "", newReleaseError(source.ErrorCodeUpstreamError, "github is down")
+			}
+
+			return "https://github.com/kubernetes-sigs/krew-index/pull/26", nil
+		}
+
+		_, err := store.do("key", release)
+		assert.NotNil(t, err)
+
+		pr, err := store.do("key", release)
+		assert.Nil(t, err)
+		assert.Equal(t, "https://github.com/kubernetes-sigs/krew-index/pull/26", pr)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("failures which are not retryable are released again", func(t *testing.T) {
+		store := newDedupeStore()
+		calls := 0
+		release := func() (string, error) {
+			calls++
+			if calls == 1 {
+				return "", newReleaseError(source.ErrorCodePluginNotFound, "plugin %q not found", "foo")
+			}
+
+			return "https://github.com/kubernetes-sigs/krew-index/pull/26", nil
+		}
+
+		_, err := store.do("key", release)
+		assert.Equal(t, `plugin "foo" not found`, fmt.Sprint(err))
+
+		//the failure can still be checked using the job
+		entry, ok := store.get("key")
+		assert.True(t, ok)
+		assert.Equal(t, `plugin "foo" not found`, fmt.Sprint(entry.err))
+
+		pr, err := store.do("key", release)
+		assert.Nil(t, err)
+		assert.Equal(t, "https://github.com/kubernetes-sigs/krew-index/pull/26", pr)
+		assert.Equal(t, 2, calls)
+	})
+}
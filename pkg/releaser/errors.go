@@ -0,0 +1,80 @@
+package releaser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+)
+
+//ReleaseError is an error when releasing the plugin, with the code returned in the webhook response
+type ReleaseError struct {
+	Code string
+	Err  error
+}
+
+func (e *ReleaseError) Error() string {
+	return e.Err.Error()
+}
+
+func newReleaseError(code string, format string, args ...interface{}) error {
+	return &ReleaseError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+//statusForCode maps the error code to http status code, and if the request can be retried
+func statusForCode(code string) (int, bool) {
+	switch code {
+	case source.ErrorCodeInvalidRequest:
+		return http.StatusBadRequest, false
+	case source.ErrorCodeUnauthorized:
+		return http.StatusUnauthorized, false
+	case source.ErrorCodeOwnershipMismatch, source.ErrorCodeProtectedFieldChanged, source.ErrorCodeURINotAllowed, source.ErrorCodeTagNotAtCommit:
+		return http.StatusForbidden, false
+	case source.ErrorCodePluginNotFound, source.ErrorCodeJobNotFound:
+		return http.StatusNotFound, false
+	case source.ErrorCodeInvalidManifest, source.ErrorCodeVersionMismatch, source.ErrorCodeInvalidAssets:
+		return http.StatusUnprocessableEntity, false
+	case source.ErrorCodeUpstreamError:
+		return http.StatusBadGateway, true
+	}
+
+	return http.StatusInternalServerError, true
+}
+
+//IsRetryable checks if releasing again later may succeed. Errors other than ReleaseError are internal errors
+func IsRetryable(err error) bool {
+	code := source.ErrorCodeInternalError
+	if releaseErr, ok := err.(*ReleaseError); ok {
+		code = releaseErr.Code
+	}
+
+	_, retryable := statusForCode(code)
+	return retryable
+}
+
+//writeError writes the error response. Errors other than ReleaseError are internal errors
+func writeError(w http.ResponseWriter, err error) {
+	code := source.ErrorCodeInternalError
+	if releaseErr, ok := err.(*ReleaseError); ok {
+		code = releaseErr.Code
+	}
+
+	status, retryable := statusForCode(code)
+	writeResponse(w, status, &source.ReleaseResponse{
+		Status: source.StatusFailed,
+		Error: &source.ResponseError{
+			Code:      code,
+			Message:   err.Error(),
+			Retryable: retryable,
+		},
+	})
+}
+
+func writeResponse(w http.ResponseWriter, status int, response *source.ReleaseResponse) {
+	response.APIVersion = source.APIVersion
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
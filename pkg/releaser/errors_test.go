@@ -0,0 +1,315 @@
+package releaser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSource struct {
+	request *source.ReleaseRequest
+	err     error
+}
+
+func (f *fakeSource) Parse(r *http.Request) (*source.ReleaseRequest, error) {
+	return f.request, f.err
+}
+
+func TestHandleActionWebhookInvalidRequest(t *testing.T) {
+	releaser := &Releaser{}
+	handler := releaser.HandleActionWebhook(&fakeSource{err: fmt.Errorf("unexpected end of JSON input")})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/github-action-webhook", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("content-type"))
+	assert.JSONEq(t, `{
+		"apiVersion": "v1",
+		"status": "failed",
+		"error": {
+			"code": "invalid_request",
+			"message": "getting release request: unexpected end of JSON input",
+			"retryable": false
+		}
+	}`, w.Body.String())
+}
+
+func TestHandleActionWebhookSourceErrors(t *testing.T) {
+	testcases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "event ignored",
+			err:            errors.Wrap(source.ErrEventIgnored, `event "ping" is not a release event`),
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"apiVersion": "v1", "status": "ignored"}`,
+		},
+		{
+			name:           "release error from source",
+			err:            newReleaseError(source.ErrorCodeUnauthorized, "header X-Hub-Signature-256 not found"),
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"apiVersion": "v1", "status": "failed", "error": {"code": "unauthorized", "message": "header X-Hub-Signature-256 not found", "retryable": false}}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			releaser := &Releaser{}
+			w := httptest.NewRecorder()
+			releaser.HandleActionWebhook(&fakeSource{err: tc.err})(w, httptest.NewRequest(http.MethodPost, "/github-release-webhook", nil))
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandleActionWebhookIdempotencyKeyMismatch(t *testing.T) {
+	releaser := &Releaser{}
+	handler := releaser.HandleActionWebhook(&fakeSource{request: &source.ReleaseRequest{TagName: "v0.0.2"}})
+
+	r := httptest.NewRequest(http.MethodPost, "/github-action-webhook", nil)
+	r.Header.Set("Idempotency-Key", "foo")
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{
+		"apiVersion": "v1",
+		"status": "failed",
+		"error": {
+			"code": "invalid_request",
+			"message": "idempotency key \"foo\" does not match the release request",
+			"retryable": false
+		}
+	}`, w.Body.String())
+}
+
+func TestWriteError(t *testing.T) {
+	testcases := []struct {
+		name              string
+		err               error
+		expectedStatus    int
+		expectedCode      string
+		expectedRetryable bool
+	}{
+		{
+			name:           "plugin not found",
+			err:            newReleaseError(source.ErrorCodePluginNotFound, "plugin %q not found", "foo"),
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   source.ErrorCodePluginNotFound,
+		},
+		{
+			name:           "ownership mismatch",
+			err:            newReleaseError(source.ErrorCodeOwnershipMismatch, "not the owner"),
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   source.ErrorCodeOwnershipMismatch,
+		},
+		{
+			name:           "invalid manifest",
+			err:            newReleaseError(source.ErrorCodeInvalidManifest, "invalid"),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   source.ErrorCodeInvalidManifest,
+		},
+		{
+			name:              "github is down",
+			err:               newReleaseError(source.ErrorCodeUpstreamError, "github is down"),
+			expectedStatus:    http.StatusBadGateway,
+			expectedCode:      source.ErrorCodeUpstreamError,
+			expectedRetryable: true,
+		},
+		{
+			name:              "unknown error",
+			err:               fmt.Errorf("something went wrong"),
+			expectedStatus:    http.StatusInternalServerError,
+			expectedCode:      source.ErrorCodeInternalError,
+			expectedRetryable: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, tc.err)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, fmt.Sprintf(`{
+				"apiVersion": "v1",
+				"status": "failed",
+				"error": {
+					"code": %q,
+					"message": %q,
+					"retryable": %t
+				}
+			}`, tc.expectedCode, tc.err.Error(), tc.expectedRetryable), w.Body.String())
+		})
+	}
+}
+
+func TestHandleJob(t *testing.T) {
+	store := newDedupeStore()
+	store.do("submitted", func() (string, error) {
+		return "https://github.com/kubernetes-sigs/krew-index/pull/26", nil
+	})
+
+	store.do("failed", func() (string, error) {
+		return "", newReleaseError(source.ErrorCodeOwnershipMismatch, "homepage does not match")
+	})
+
+	finish := make(chan struct{})
+	defer close(finish)
+	store.start("pending", func() (string, error) {
+		<-finish
+		return "", nil
+	})
+
+	testcases := []struct {
+		name           string
+		id             string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "submitted",
+			id:             "submitted",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"apiVersion": "v1", "status": "submitted", "job_id": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`,
+		},
+		{
+			name:           "pending",
+			id:             "pending",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"apiVersion": "v1", "status": "pending", "job_id": "pending"}`,
+		},
+		{
+			name:           "failed",
+			id:             "failed",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"apiVersion": "v1", "status": "failed", "error": {"code": "ownership_mismatch", "message": "homepage does not match", "retryable": false}}`,
+		},
+		{
+			name:           "not found",
+			id:             "foo",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"apiVersion": "v1", "status": "failed", "error": {"code": "job_not_found", "message": "job \"foo\" not found", "retryable": false}}`,
+		},
+	}
+
+	releaser := &Releaser{dedupe: store}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			releaser.HandleJob()(w, httptest.NewRequest(http.MethodGet, "/jobs/"+tc.id, nil))
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+type fakeRenderingSource struct {
+	fakeSource
+	rendered  chan *source.ReleaseRequest
+	renderErr error
+	reported  []error
+}
+
+func (f *fakeRenderingSource) Render(request *source.ReleaseRequest, policy *Policy) error {
+	f.rendered <- request
+	return f.renderErr
+}
+
+func (f *fakeRenderingSource) ReportFailure(request *source.ReleaseRequest, err error) {
+	f.reported = append(f.reported, err)
+}
+
+func TestHandleReleaseEvent(t *testing.T) {
+	request := &source.ReleaseRequest{TagName: "v0.0.2", PluginOwner: "foo-bar", PluginRepo: "my-awesome-plugin"}
+	hook := &fakeRenderingSource{
+		fakeSource: fakeSource{request: request},
+		rendered:   make(chan *source.ReleaseRequest, 1),
+		renderErr:  newReleaseError(source.ErrorCodeInvalidManifest, "template .krew.yaml not found at tag %q", "v0.0.2"),
+	}
+
+	releaser := &Releaser{dedupe: newDedupeStore()}
+
+	//the event is acknowledged before the manifest is rendered
+	w := httptest.NewRecorder()
+	releaser.HandleReleaseEvent(hook)(w, httptest.NewRequest(http.MethodPost, "/github-release-webhook", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	key := source.IdempotencyKey(request)
+	assert.JSONEq(t, fmt.Sprintf(`{"apiVersion": "v1", "status": "pending", "job_id": %q}`, key), w.Body.String())
+	assert.Equal(t, request, <-hook.rendered)
+
+	entry, ok := releaser.dedupe.get(key)
+	assert.True(t, ok)
+	<-entry.done
+	assert.Equal(t, `template .krew.yaml not found at tag "v0.0.2"`, entry.err.Error())
+
+	//the failure is reported to the plugin repo, as the event was already acknowledged
+	assert.Equal(t, []error{entry.err}, hook.reported)
+
+	//failures which may succeed when retried are not reported
+	hook.reported = nil
+	hook.renderErr = newReleaseError(source.ErrorCodeUpstreamError, "github is down")
+	w = httptest.NewRecorder()
+	releaser.HandleReleaseEvent(hook)(w, httptest.NewRequest(http.MethodPost, "/github-release-webhook", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	<-hook.rendered
+
+	entry, ok = releaser.dedupe.get(key)
+	assert.True(t, ok)
+	<-entry.done
+	assert.Equal(t, "github is down", entry.err.Error())
+	assert.Len(t, hook.reported, 0)
+
+	//ignored events are not released
+	hook.err = errors.Wrap(source.ErrEventIgnored, "event \"ping\" is not a release event")
+	w = httptest.NewRecorder()
+	releaser.HandleReleaseEvent(hook)(w, httptest.NewRequest(http.MethodPost, "/github-release-webhook", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, hook.rendered, 0)
+}
+
+func TestReleaseOnceSharesJobWithReleaseEvent(t *testing.T) {
+	request := &source.ReleaseRequest{TagName: "v0.0.2", PluginOwner: "foo-bar", PluginRepo: "my-awesome-plugin"}
+	releaser := &Releaser{dedupe: newDedupeStore()}
+
+	//the release event opened the PR
+	pr, err := releaser.dedupe.do(source.IdempotencyKey(request), func() (string, error) {
+		return "https://github.com/kubernetes-sigs/krew-index/pull/26", nil
+	})
+	assert.Nil(t, err)
+
+	//the poller finds the same release, and gets the PR instead of rendering and releasing it again
+	polled := &source.ReleaseRequest{TagName: "v0.0.2", PluginOwner: "foo-bar", PluginRepo: "my-awesome-plugin"}
+	polledPR, err := releaser.ReleaseOnce(polled, func(request *source.ReleaseRequest) error {
+		t.Error("release rendered again by the poller")
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, pr, polledPR)
+
+	//releases which failed are rendered again
+	failed := &source.ReleaseRequest{TagName: "v0.0.3", PluginOwner: "foo-bar", PluginRepo: "my-awesome-plugin"}
+	_, err = releaser.dedupe.do(source.IdempotencyKey(failed), func() (string, error) {
+		return "", newReleaseError(source.ErrorCodeUpstreamError, "github is down")
+	})
+	assert.NotNil(t, err)
+
+	_, err = releaser.ReleaseOnce(failed, func(request *source.ReleaseRequest) error {
+		return newReleaseError(source.ErrorCodeInvalidManifest, "template .krew.yaml not found at tag %q", request.TagName)
+	})
+	assert.EqualError(t, err, `template .krew.yaml not found at tag "v0.0.3"`)
+}
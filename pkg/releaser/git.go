@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v29/github"
@@ -158,28 +159,37 @@ func (r *Releaser) submitPR(request *source.ReleaseRequest) (string, error) {
 }
 
 func (r *Releaser) getTitle(request *source.ReleaseRequest) *string {
-	s := fmt.Sprintf(
+	return github.String(PRTitle(request))
+}
+
+//PRTitle is the title of the PR opened for the release request
+func PRTitle(request *source.ReleaseRequest) string {
+	return fmt.Sprintf(
 		"release new version %s of %s",
 		request.TagName,
 		request.PluginName,
 	)
-
-	return github.String(s)
 }
 
 func (r *Releaser) getBranchName(request *source.ReleaseRequest) *string {
-	s := fmt.Sprintf("%s-%s-%s", request.PluginOwner, request.PluginRepo, request.TagName)
+	//tags of monorepos may have / e.g. kubectl-foo/v1.2.3
+	tag := strings.ReplaceAll(request.TagName, "/", "-")
+	s := fmt.Sprintf("%s-%s-%s", request.PluginOwner, request.PluginRepo, tag)
 	fmt.Printf("creating branch %s", s)
 	return github.String(s)
 }
 
 func (r *Releaser) getHead(request *source.ReleaseRequest) *string {
 	branchName := r.getBranchName(request)
-	s := fmt.Sprintf("%s:%s", r.TokenUserHandle, *branchName)
+	s := fmt.Sprintf("%s:%s", r.LocalKrewIndexRepoOwner, *branchName)
 	return github.String(s)
 }
 
 func (r *Releaser) getPRBody(request *source.ReleaseRequest) *string {
+	if r.Standalone {
+		return github.String(r.getStandalonePRBody(request))
+	}
+
 	prBody := `hey krew-index team,
 
 I am [krew-release-bot](https://github.com/rajatjindal/krew-release-bot), and I would like to open this PR to publish version %s of %s on behalf of [%s](https://github.com/%s).
@@ -197,6 +207,36 @@ Thanks,
 	return github.String(s)
 }
 
+//getStandalonePRBody is the body of the PR opened by the user the token belongs to,
+//using krew-release-bot action in standalone mode
+func (r *Releaser) getStandalonePRBody(request *source.ReleaseRequest) string {
+	prBody := `hey krew-index team,
+
+I would like to open this PR to publish version %s of %s, released by [%s](https://github.com/%s).
+
+This PR was opened by [%s](https://github.com/%s) using [krew-release-bot](https://github.com/rajatjindal/krew-release-bot) in standalone mode.
+
+Thanks,
+[%s](https://github.com/%s)`
+
+	s := fmt.Sprintf(prBody,
+		fmt.Sprintf("`%s`", request.TagName),
+		fmt.Sprintf("`%s`", request.PluginName),
+		request.PluginReleaseActor,
+		request.PluginReleaseActor,
+		r.TokenUserHandle,
+		r.TokenUserHandle,
+		r.TokenUsername,
+		r.TokenUserHandle,
+	)
+
+	if request.SignatureVerification != "" {
+		s = fmt.Sprintf("%s\n\n**Signature verification**\n%s", s, request.SignatureVerification)
+	}
+
+	return s
+}
+
 func (r *Releaser) getAuth() transport.AuthMethod {
 	return &githttp.BasicAuth{
 		Username: r.TokenUserHandle,
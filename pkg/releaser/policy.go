@@ -0,0 +1,228 @@
+package releaser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/krew"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/yaml"
+)
+
+//Policy is the server side policy applied to plugin manifests before opening the PR
+type Policy struct {
+	Plugins map[string]PluginPolicy `json:"plugins"`
+}
+
+//PluginPolicy is the policy for one plugin
+type PluginPolicy struct {
+	//AllowedHosts are the hosts, in addition to the release assets of plugin repo,
+	//from which the platform uri can be downloaded
+	AllowedHosts []string `json:"allowedHosts"`
+
+	//ApprovedHomepage is the new homepage approved by the krew-release-bot maintainers,
+	//when ownership of the plugin is transferred to another repo
+	ApprovedHomepage string `json:"approvedHomepage"`
+
+	//TagPattern is the regular expression, with named capture version, matching the version
+	//in tags of the plugin that do not end with the version
+	TagPattern string `json:"tagPattern"`
+}
+
+//LoadPolicy loads the policy from yaml file
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s. error: %v", file, err)
+	}
+
+	return policy, nil
+}
+
+//ParsePolicy parses the policy from yaml
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	err := yaml.UnmarshalStrict(data, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+//PolicyFile is the policy loaded from a file. The file is loaded again when it is modified, so
+//that approvals by krew-release-bot maintainers take effect without restarting the server
+type PolicyFile struct {
+	file string
+
+	mu      sync.Mutex
+	policy  *Policy
+	modTime time.Time
+}
+
+//NewPolicyFile loads the policy from the yaml file
+func NewPolicyFile(file string) (*PolicyFile, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := LoadPolicy(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PolicyFile{file: file, policy: policy, modTime: info.ModTime()}, nil
+}
+
+//StaticPolicy returns the policy file for a policy which is not loaded from a file,
+//e.g. the policy input of the action in standalone mode
+func StaticPolicy(policy *Policy) *PolicyFile {
+	return &PolicyFile{policy: policy}
+}
+
+//Policy returns the policy, loading the file again if it was modified. If the modified
+//file cannot be loaded, the policy loaded last is used
+func (f *PolicyFile) Policy() *Policy {
+	if f == nil {
+		return nil
+	}
+
+	if f.file == "" {
+		return f.policy
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.file)
+	if err != nil {
+		logrus.Errorf("policy file %s not found, using the policy loaded at %s. error: %v", f.file, f.modTime, err)
+		return f.policy
+	}
+
+	if info.ModTime().Equal(f.modTime) {
+		return f.policy
+	}
+
+	policy, err := LoadPolicy(f.file)
+	if err != nil {
+		logrus.Errorf("reloading policy file failed, using the policy loaded at %s. error: %v", f.modTime, err)
+		return f.policy
+	}
+
+	logrus.Infof("reloaded policy file %s", f.file)
+	f.policy = policy
+	f.modTime = info.ModTime()
+	return f.policy
+}
+
+//ValidateURIs validates that the uri of all platforms are release assets of the plugin repo
+//for the tag being released, or are hosted on one of the allowed hosts for the plugin
+func (p *Policy) ValidateURIs(request *source.ReleaseRequest, plugin *index.Plugin) error {
+	prefix := releaseAssetsPrefix(request)
+	allowedHosts := p.allowedHosts(plugin.GetName())
+
+	for _, platform := range plugin.Spec.Platforms {
+		err := validateURI(platform.URI, prefix, allowedHosts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//ValidateURI validates the uri before it is downloaded when rendering the template on the server.
+//Hosts allowed for the plugin are used only if the plugin name is known before rendering
+func (p *Policy) ValidateURI(request *source.ReleaseRequest, uri string) error {
+	return validateURI(uri, releaseAssetsPrefix(request), p.allowedHosts(request.PluginName))
+}
+
+func releaseAssetsPrefix(request *source.ReleaseRequest) string {
+	return fmt.Sprintf("https://github.com/%s/%s/releases/download/%s/", request.PluginOwner, request.PluginRepo, request.TagName)
+}
+
+func (p *Policy) allowedHosts(pluginName string) []string {
+	if p == nil || pluginName == "" {
+		return []string{}
+	}
+
+	return p.Plugins[pluginName].AllowedHosts
+}
+
+func validateURI(uri, prefix string, allowedHosts []string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("uri %q is invalid. error: %v", uri, err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("uri %q must use https", uri)
+	}
+
+	if u.User != nil {
+		return fmt.Errorf("uri %q must not contain user info", uri)
+	}
+
+	//compare the parsed url, so that the prefix cannot be bypassed using e.g. ../
+	cleaned := u.Scheme + "://" + u.Host + u.EscapedPath()
+	if strings.HasPrefix(cleaned, prefix) && !strings.Contains(cleaned[len(prefix):], "/") {
+		return nil
+	}
+
+	for _, host := range allowedHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("uri %q is not a release asset of %s, and host %q is not in the allowed hosts for the plugin", uri, strings.TrimSuffix(prefix, "/"), u.Hostname())
+}
+
+//ValidateVersion validates that spec.version of the plugin matches the version of the released tag,
+//matched using the tag pattern of the plugin in the policy if any
+func (p *Policy) ValidateVersion(request *source.ReleaseRequest, plugin *index.Plugin) error {
+	tagPattern := ""
+	if p != nil {
+		tagPattern = p.Plugins[plugin.GetName()].TagPattern
+	}
+
+	return source.ValidateVersion(plugin.Spec.Version, request.TagName, tagPattern)
+}
+
+//ApproveChanges verifies that changes to the protected fields of the plugin manifest are approved.
+//Only a change of homepage can be approved, as renaming the plugin needs a new plugin manifest anyways
+func (p *Policy) ApproveChanges(pluginName string, changes []krew.FieldChange) error {
+	approvedHomepage := ""
+	if p != nil {
+		approvedHomepage = p.Plugins[pluginName].ApprovedHomepage
+	}
+
+	unapproved := []string{}
+	for _, change := range changes {
+		if change.Field == "homepage" && approvedHomepage != "" && krew.NormalizeHomepage(change.New) == krew.NormalizeHomepage(approvedHomepage) {
+			continue
+		}
+
+		unapproved = append(unapproved, fmt.Sprintf("%s changed from %q to %q", change.Field, change.Old, change.New))
+	}
+
+	if len(unapproved) > 0 {
+		return fmt.Errorf("changes to protected fields need approval from krew-release-bot maintainers: %s", strings.Join(unapproved, ", "))
+	}
+
+	return nil
+}
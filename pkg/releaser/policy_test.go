@@ -0,0 +1,340 @@
+package releaser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/krew"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/yaml"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy("data/policy.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"downloads.example.com"}, policy.Plugins["whoami"].AllowedHosts)
+
+	_, err = LoadPolicy("data/does-not-exist.yaml")
+	assert.NotNil(t, err)
+}
+
+func TestStaticPolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte("plugins:\n  whoami:\n    allowedHosts:\n    - downloads.example.com\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, policy, StaticPolicy(policy).Policy())
+
+	_, err = ParsePolicy([]byte("plugins:\n  whoami:\n    allowedHost: downloads.example.com\n"))
+	assert.NotNil(t, err)
+}
+
+func TestPolicyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "policy.yaml")
+	err = ioutil.WriteFile(file, []byte("plugins: {}\n"), 0644)
+	assert.Nil(t, err)
+
+	policyFile, err := NewPolicyFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "", policyFile.Policy().Plugins["whoami"].ApprovedHomepage)
+
+	//approval added by maintainers, without restarting the server
+	err = ioutil.WriteFile(file, []byte("plugins:\n  whoami:\n    approvedHomepage: https://github.com/someone-else/kubectl-whoami\n"), 0644)
+	assert.Nil(t, err)
+	err = os.Chtimes(file, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.com/someone-else/kubectl-whoami", policyFile.Policy().Plugins["whoami"].ApprovedHomepage)
+
+	//invalid policy keeps the policy loaded last
+	err = ioutil.WriteFile(file, []byte("plugins:\n  whoami:\n    unknownField: true\n"), 0644)
+	assert.Nil(t, err)
+	err = os.Chtimes(file, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, "https://github.com/someone-else/kubectl-whoami", policyFile.Policy().Plugins["whoami"].ApprovedHomepage)
+
+	var nilPolicyFile *PolicyFile
+	assert.Nil(t, nilPolicyFile.Policy())
+}
+
+func TestValidateURIs(t *testing.T) {
+	policy := &Policy{
+		Plugins: map[string]PluginPolicy{
+			"whoami": {AllowedHosts: []string{"downloads.example.com"}},
+		},
+	}
+
+	request := &source.ReleaseRequest{
+		TagName:     "v0.0.2",
+		PluginOwner: "rajatjindal",
+		PluginRepo:  "kubectl-whoami",
+	}
+
+	testcases := []struct {
+		name          string
+		policy        *Policy
+		pluginName    string
+		uri           string
+		expectedError string
+	}{
+		{
+			name:       "release asset of the plugin repo",
+			policy:     policy,
+			pluginName: "whoami",
+			uri:        "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz",
+		},
+		{
+			name:       "release asset of the plugin repo without policy",
+			pluginName: "whoami",
+			uri:        "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz",
+		},
+		{
+			name:          "release asset of another tag",
+			policy:        policy,
+			pluginName:    "whoami",
+			uri:           "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.1/kubectl-whoami_v0.0.1_linux_amd64.tar.gz",
+			expectedError: `uri "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.1/kubectl-whoami_v0.0.1_linux_amd64.tar.gz" is not a release asset of https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2, and host "github.com" is not in the allowed hosts for the plugin`,
+		},
+		{
+			name:          "release asset of another repo",
+			policy:        policy,
+			pluginName:    "whoami",
+			uri:           "https://github.com/someone-else/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz",
+			expectedError: `uri "https://github.com/someone-else/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz" is not a release asset of https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2, and host "github.com" is not in the allowed hosts for the plugin`,
+		},
+		{
+			name:          "path traversal out of the release",
+			policy:        policy,
+			pluginName:    "whoami",
+			uri:           "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/../../../../someone-else/foo.tar.gz",
+			expectedError: `uri "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/../../../../someone-else/foo.tar.gz" is not a release asset of https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2, and host "github.com" is not in the allowed hosts for the plugin`,
+		},
+		{
+			name:       "allowed host for the plugin",
+			policy:     policy,
+			pluginName: "whoami",
+			uri:        "https://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz",
+		},
+		{
+			name:          "allowed host of another plugin",
+			policy:        policy,
+			pluginName:    "foo",
+			uri:           "https://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz",
+			expectedError: `uri "https://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz" is not a release asset of https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2, and host "downloads.example.com" is not in the allowed hosts for the plugin`,
+		},
+		{
+			name:          "http is not allowed",
+			policy:        policy,
+			pluginName:    "whoami",
+			uri:           "http://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz",
+			expectedError: `uri "http://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz" must use https`,
+		},
+		{
+			name:          "user info is not allowed",
+			policy:        policy,
+			pluginName:    "whoami",
+			uri:           "https://github.com@evil.example.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/foo.tar.gz",
+			expectedError: `uri "https://github.com@evil.example.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/foo.tar.gz" must not contain user info`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			plugin := &index.Plugin{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.pluginName},
+				Spec: index.PluginSpec{
+					Platforms: []index.Platform{{URI: tc.uri}},
+				},
+			}
+
+			err := tc.policy.ValidateURIs(request, plugin)
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				if err != nil {
+					assert.Equal(t, tc.expectedError, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateURI(t *testing.T) {
+	policy := &Policy{
+		Plugins: map[string]PluginPolicy{
+			"whoami": {AllowedHosts: []string{"downloads.example.com"}},
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		pluginName    string
+		uri           string
+		expectedError string
+	}{
+		{
+			name: "release asset of the plugin repo",
+			uri:  "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz",
+		},
+		{
+			name:       "allowed host when plugin name is known",
+			pluginName: "whoami",
+			uri:        "https://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz",
+		},
+		{
+			name:          "allowed host when plugin name is not known yet",
+			uri:           "https://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz",
+			expectedError: `uri "https://downloads.example.com/whoami/v0.0.2/linux-amd64.tar.gz" is not a release asset of https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2, and host "downloads.example.com" is not in the allowed hosts for the plugin`,
+		},
+		{
+			name:          "metadata endpoint",
+			pluginName:    "whoami",
+			uri:           "http://169.254.169.254/latest/meta-data/",
+			expectedError: `uri "http://169.254.169.254/latest/meta-data/" must use https`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := &source.ReleaseRequest{
+				TagName:     "v0.0.2",
+				PluginOwner: "rajatjindal",
+				PluginRepo:  "kubectl-whoami",
+				PluginName:  tc.pluginName,
+			}
+
+			err := policy.ValidateURI(request, tc.uri)
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				if err != nil {
+					assert.Equal(t, tc.expectedError, err.Error())
+				}
+			}
+		})
+	}
+}
+
+//TestPolicyValidateVersion validates the versions using the cases the action is tested with,
+//so that a release passing validation in the action is not rejected by the server
+func TestPolicyValidateVersion(t *testing.T) {
+	data, err := ioutil.ReadFile("../source/data/versions/versions.yaml")
+	assert.Nil(t, err)
+
+	testcases := []struct {
+		Name          string `json:"name"`
+		Tag           string `json:"tag"`
+		TagPattern    string `json:"tagPattern"`
+		Version       string `json:"version"`
+		SpecVersion   string `json:"specVersion"`
+		ExpectedError string `json:"expectedError"`
+	}{}
+	err = yaml.UnmarshalStrict(data, &testcases)
+	assert.Nil(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			policy := &Policy{
+				Plugins: map[string]PluginPolicy{
+					"foo": {TagPattern: tc.TagPattern},
+				},
+			}
+
+			plugin := &index.Plugin{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec:       index.PluginSpec{Version: tc.SpecVersion},
+			}
+
+			err := policy.ValidateVersion(&source.ReleaseRequest{TagName: tc.Tag, Version: tc.Version}, plugin)
+			if tc.ExpectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				if err != nil {
+					assert.Equal(t, tc.ExpectedError, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestApproveChanges(t *testing.T) {
+	policy := &Policy{
+		Plugins: map[string]PluginPolicy{
+			"whoami": {ApprovedHomepage: "https://github.com/someone-else/kubectl-whoami"},
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		policy        *Policy
+		changes       []krew.FieldChange
+		expectedError string
+	}{
+		{
+			name:    "no changes",
+			policy:  policy,
+			changes: []krew.FieldChange{},
+		},
+		{
+			name:   "approved homepage change",
+			policy: policy,
+			changes: []krew.FieldChange{
+				{Field: "homepage", Old: "https://github.com/rajatjindal/kubectl-whoami", New: "https://github.com/someone-else/kubectl-whoami"},
+			},
+		},
+		{
+			name:   "approved homepage change with trailing slash",
+			policy: policy,
+			changes: []krew.FieldChange{
+				{Field: "homepage", Old: "https://github.com/rajatjindal/kubectl-whoami", New: "https://github.com/someone-else/kubectl-whoami/"},
+			},
+		},
+		{
+			name: "homepage change without policy",
+			changes: []krew.FieldChange{
+				{Field: "homepage", Old: "https://github.com/rajatjindal/kubectl-whoami", New: "https://github.com/someone-else/kubectl-whoami"},
+			},
+			expectedError: `changes to protected fields need approval from krew-release-bot maintainers: homepage changed from "https://github.com/rajatjindal/kubectl-whoami" to "https://github.com/someone-else/kubectl-whoami"`,
+		},
+		{
+			name:   "homepage changed to another than approved",
+			policy: policy,
+			changes: []krew.FieldChange{
+				{Field: "homepage", Old: "https://github.com/rajatjindal/kubectl-whoami", New: "https://github.com/evil/kubectl-whoami"},
+			},
+			expectedError: `changes to protected fields need approval from krew-release-bot maintainers: homepage changed from "https://github.com/rajatjindal/kubectl-whoami" to "https://github.com/evil/kubectl-whoami"`,
+		},
+		{
+			name:   "name change is never approved",
+			policy: policy,
+			changes: []krew.FieldChange{
+				{Field: "name", Old: "whoami", New: "whoareyou"},
+			},
+			expectedError: `changes to protected fields need approval from krew-release-bot maintainers: name changed from "whoami" to "whoareyou"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.ApproveChanges("whoami", tc.changes)
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				if err != nil {
+					assert.Equal(t, tc.expectedError, err.Error())
+				}
+			}
+		})
+	}
+}
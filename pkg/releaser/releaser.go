@@ -1,12 +1,18 @@
 package releaser
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
 
+	"github.com/google/go-github/v29/github"
 	"github.com/pkg/errors"
 	"github.com/rajatjindal/krew-release-bot/pkg/krew"
-	"github.com/rajatjindal/krew-release-bot/pkg/source/actions"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
 )
 
 //Releaser is what opens PR
@@ -21,6 +27,16 @@ type Releaser struct {
 	LocalKrewIndexRepo            string
 	LocalKrewIndexRepoOwner       string
 	LocalKrewIndexRepoCloneURL    string
+
+	//PolicyFile is the policy applied to plugin manifests before opening PR
+	PolicyFile *PolicyFile
+
+	//Standalone is true when the PR is opened by the user the token belongs to,
+	//instead of by krew-release-bot
+	Standalone bool
+
+	//dedupe makes sure retried requests to the webhook do not open another PR
+	dedupe *dedupeStore
 }
 
 func getCloneURL(owner, repo string) string {
@@ -47,29 +63,243 @@ func New(ghToken string) *Releaser {
 		LocalKrewIndexRepo:            krew.GetKrewIndexRepoName(),
 		LocalKrewIndexRepoOwner:       tokenUserHandle,
 		LocalKrewIndexRepoCloneURL:    "https://github.com/krew-release-bot/krew-index.git",
+		dedupe:                        newDedupeStore(),
 	}
 }
 
-//HandleActionWebhook handles requests from github actions
-func (releaser *Releaser) HandleActionWebhook(w http.ResponseWriter, r *http.Request) {
-	hook, err := actions.NewGithubActions()
+//NewForUser returns new releaser object, that pushes to the krew-index fork of the user the token belongs to.
+//fork is <owner>/<repo> of the fork, and defaults to <user>/krew-index
+func NewForUser(ghToken, fork string) (*Releaser, error) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: ghToken})
+	client := github.NewClient(oauth2.NewClient(context.TODO(), ts))
+
+	user, _, err := client.Users.Get(context.TODO(), "")
 	if err != nil {
-		http.Error(w, errors.Wrap(err, "creating instance of action handler").Error(), http.StatusInternalServerError)
-		return
+		return nil, errors.Wrap(err, "getting user for the token")
 	}
 
-	releaseRequest, err := hook.Parse(r)
-	if err != nil {
-		http.Error(w, errors.Wrap(err, "getting release request").Error(), http.StatusInternalServerError)
-		return
+	email := user.GetEmail()
+	if email == "" {
+		email = fmt.Sprintf("%d+%s@users.noreply.github.com", user.GetID(), user.GetLogin())
 	}
 
-	pr, err := releaser.Release(releaseRequest)
-	if err != nil {
-		http.Error(w, errors.Wrap(err, "opening pr").Error(), http.StatusInternalServerError)
-		return
+	name := user.GetName()
+	if name == "" {
+		name = user.GetLogin()
+	}
+
+	forkOwner, forkRepo := user.GetLogin(), krew.GetKrewIndexRepoName()
+	if fork != "" {
+		s := strings.Split(fork, "/")
+		if len(s) != 2 || s[0] == "" || s[1] == "" {
+			return nil, fmt.Errorf("krew-index fork is incorrect format. expected format <owner>/<repo>, found %q", fork)
+		}
+
+		forkOwner, forkRepo = s[0], s[1]
+	}
+
+	return &Releaser{
+		Token:                         ghToken,
+		TokenEmail:                    email,
+		TokenUserHandle:               user.GetLogin(),
+		TokenUsername:                 name,
+		UpstreamKrewIndexRepo:         krew.GetKrewIndexRepoName(),
+		UpstreamKrewIndexRepoOwner:    krew.GetKrewIndexRepoOwner(),
+		UpstreamKrewIndexRepoCloneURL: getCloneURL(krew.GetKrewIndexRepoOwner(), krew.GetKrewIndexRepoName()),
+		LocalKrewIndexRepo:            forkRepo,
+		LocalKrewIndexRepoOwner:       forkOwner,
+		LocalKrewIndexRepoCloneURL:    getCloneURL(forkOwner, forkRepo),
+		Standalone:                    true,
+	}, nil
+}
+
+//HandleActionWebhook returns the handler for requests from github actions
+func (releaser *Releaser) HandleActionWebhook(hook source.Source) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		releaseRequest, err := hook.Parse(r)
+		if errors.Cause(err) == source.ErrEventIgnored {
+			logrus.Info(err)
+			writeResponse(w, http.StatusOK, &source.ReleaseResponse{Status: source.StatusIgnored})
+			return
+		}
+
+		if err != nil {
+			if _, ok := err.(*ReleaseError); !ok {
+				err = &ReleaseError{Code: source.ErrorCodeInvalidRequest, Err: errors.Wrap(err, "getting release request")}
+			}
+
+			writeError(w, err)
+			return
+		}
+
+		key := source.IdempotencyKey(releaseRequest)
+		if header := r.Header.Get(source.IdempotencyKeyHeader); header != "" && header != key {
+			writeError(w, newReleaseError(source.ErrorCodeInvalidRequest, "idempotency key %q does not match the release request", header))
+			return
+		}
+
+		if r.Header.Get(source.PreferHeader) == source.PreferRespondAsync && releaser.dedupe != nil {
+			releaser.dedupe.start(key, logResult(releaseRequest, func() (string, error) {
+				return releaser.Release(releaseRequest)
+			}))
+
+			writeResponse(w, http.StatusAccepted, &source.ReleaseResponse{
+				Status: source.StatusPending,
+				JobID:  key,
+			})
+			return
+		}
+
+		pr, err := releaser.release(key, releaseRequest)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeResponse(w, http.StatusOK, &source.ReleaseResponse{
+			Status: source.StatusSubmitted,
+			PRURL:  pr,
+			JobID:  key,
+		})
+	}
+}
+
+//RenderingSource is the source of release requests parsed without the manifest, e.g. release events
+//sent by github. The manifest is rendered in the background, as it needs downloading the archives
+type RenderingSource interface {
+	source.Source
+
+	//Render renders the manifest of the request, downloading only the uris allowed by the policy
+	Render(request *source.ReleaseRequest, policy *Policy) error
+
+	//ReportFailure reports the release which failed in the background to the plugin repo,
+	//as the response to the request was sent before it failed
+	ReportFailure(request *source.ReleaseRequest, err error)
+}
+
+//HandleReleaseEvent returns the handler for release events from the hook. The event is acknowledged
+//right away, as github does not wait more than 10 seconds for the response, and the manifest is rendered
+//and released in the background, once for the plugin repo and tag. The releaser must be created using New
+func (releaser *Releaser) HandleReleaseEvent(hook RenderingSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		releaseRequest, err := hook.Parse(r)
+		if errors.Cause(err) == source.ErrEventIgnored {
+			logrus.Info(err)
+			writeResponse(w, http.StatusOK, &source.ReleaseResponse{Status: source.StatusIgnored})
+			return
+		}
+
+		if err != nil {
+			if _, ok := err.(*ReleaseError); !ok {
+				err = &ReleaseError{Code: source.ErrorCodeInvalidRequest, Err: errors.Wrap(err, "getting release request")}
+			}
+
+			writeError(w, err)
+			return
+		}
+
+		//the manifest is not rendered yet, so the key is derived from the plugin repo and tag
+		key := source.IdempotencyKey(releaseRequest)
+		release := releaser.renderAndRelease(releaseRequest, func(request *source.ReleaseRequest) error {
+			return hook.Render(request, releaser.PolicyFile.Policy())
+		})
+
+		releaser.dedupe.start(key, logResult(releaseRequest, func() (string, error) {
+			pr, err := release()
+			if err != nil && !IsRetryable(err) {
+				hook.ReportFailure(releaseRequest, err)
+			}
+
+			return pr, err
+		}))
+		writeResponse(w, http.StatusAccepted, &source.ReleaseResponse{
+			Status: source.StatusPending,
+			JobID:  key,
+		})
+	}
+}
+
+//HandleJob returns the handler for checking the status of the release in the background.
+//The id of the job is the last element of the path e.g. /jobs/<id>
+func (releaser *Releaser) HandleJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := path.Base(r.URL.Path)
+
+		var entry *dedupeEntry
+		ok := false
+		if releaser.dedupe != nil {
+			entry, ok = releaser.dedupe.get(id)
+		}
+
+		if !ok {
+			writeError(w, newReleaseError(source.ErrorCodeJobNotFound, "job %q not found", id))
+			return
+		}
+
+		if !entry.finished() {
+			writeResponse(w, http.StatusOK, &source.ReleaseResponse{
+				Status: source.StatusPending,
+				JobID:  id,
+			})
+			return
+		}
+
+		if entry.err != nil {
+			writeError(w, entry.err)
+			return
+		}
+
+		writeResponse(w, http.StatusOK, &source.ReleaseResponse{
+			Status: source.StatusSubmitted,
+			PRURL:  entry.pr,
+			JobID:  id,
+		})
+	}
+}
+
+//ReleaseOnce renders and releases the request once for the plugin repo and tag, sharing the job with
+//release events for them, e.g. when the poller finds the release while the event is being handled.
+//The releaser must be created using New
+func (releaser *Releaser) ReleaseOnce(request *source.ReleaseRequest, render func(request *source.ReleaseRequest) error) (string, error) {
+	//the manifest is not rendered yet, so the key is derived from the plugin repo and tag
+	key := source.IdempotencyKey(request)
+	return releaser.dedupe.do(key, releaser.renderAndRelease(request, render))
+}
+
+//renderAndRelease returns the job rendering the request, and releasing it once for the idempotency key
+//of the rendered manifest, so that it does not race a request from the action with the same manifest
+func (releaser *Releaser) renderAndRelease(request *source.ReleaseRequest, render func(request *source.ReleaseRequest) error) func() (string, error) {
+	return func() (string, error) {
+		err := render(request)
+		if err != nil {
+			return "", err
+		}
+
+		return releaser.release(source.IdempotencyKey(request), request)
+	}
+}
+
+//logResult logs the PR opened, or the error, of the release in the background, as nobody waits for its response
+func logResult(request *source.ReleaseRequest, release func() (string, error)) func() (string, error) {
+	return func() (string, error) {
+		pr, err := release()
+		if err != nil {
+			logrus.Errorf("releasing tag %q of %s/%s in the background failed. error: %v", request.TagName, request.PluginOwner, request.PluginRepo, err)
+			return "", err
+		}
+
+		logrus.Infof("released tag %q of %s/%s in the background in %s", request.TagName, request.PluginOwner, request.PluginRepo, pr)
+		return pr, nil
+	}
+}
+
+//release releases the request once for the idempotency key
+func (releaser *Releaser) release(key string, request *source.ReleaseRequest) (string, error) {
+	if releaser.dedupe == nil {
+		return releaser.Release(request)
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf("PR %q submitted successfully", pr)))
+	return releaser.dedupe.do(key, func() (string, error) {
+		return releaser.Release(request)
+	})
 }
@@ -0,0 +1,104 @@
+package releaser
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestNewForUser(t *testing.T) {
+	testcases := []struct {
+		name             string
+		user             string
+		fork             string
+		expectedReleaser *Releaser
+		expectedError    string
+	}{
+		{
+			name: "fork of the user",
+			user: `{"login": "foo-bar", "id": 1234, "name": "Foo Bar", "email": "foo@example.com"}`,
+			expectedReleaser: &Releaser{
+				Token:                         "token",
+				TokenEmail:                    "foo@example.com",
+				TokenUserHandle:               "foo-bar",
+				TokenUsername:                 "Foo Bar",
+				UpstreamKrewIndexRepo:         "krew-index",
+				UpstreamKrewIndexRepoOwner:    "kubernetes-sigs",
+				UpstreamKrewIndexRepoCloneURL: "https://github.com/kubernetes-sigs/krew-index.git",
+				LocalKrewIndexRepo:            "krew-index",
+				LocalKrewIndexRepoOwner:       "foo-bar",
+				LocalKrewIndexRepoCloneURL:    "https://github.com/foo-bar/krew-index.git",
+				Standalone:                    true,
+			},
+		},
+		{
+			name: "fork in an org, user without public name and email",
+			user: `{"login": "foo-bar", "id": 1234}`,
+			fork: "my-org/my-krew-index",
+			expectedReleaser: &Releaser{
+				Token:                         "token",
+				TokenEmail:                    "1234+foo-bar@users.noreply.github.com",
+				TokenUserHandle:               "foo-bar",
+				TokenUsername:                 "foo-bar",
+				UpstreamKrewIndexRepo:         "krew-index",
+				UpstreamKrewIndexRepoOwner:    "kubernetes-sigs",
+				UpstreamKrewIndexRepoCloneURL: "https://github.com/kubernetes-sigs/krew-index.git",
+				LocalKrewIndexRepo:            "my-krew-index",
+				LocalKrewIndexRepoOwner:       "my-org",
+				LocalKrewIndexRepoCloneURL:    "https://github.com/my-org/my-krew-index.git",
+				Standalone:                    true,
+			},
+		},
+		{
+			name:          "fork in incorrect format",
+			user:          `{"login": "foo-bar", "id": 1234}`,
+			fork:          "my-krew-index",
+			expectedError: `krew-index fork is incorrect format. expected format <owner>/<repo>, found "my-krew-index"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			gock.New("https://api.github.com").
+				Get("/user").
+				MatchHeader("Authorization", "Bearer token").
+				Reply(200).
+				BodyString(tc.user)
+
+			r, err := NewForUser("token", tc.fork)
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedReleaser, r)
+			} else {
+				assert.NotNil(t, err)
+				if err != nil {
+					assert.Equal(t, tc.expectedError, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestGetPRBody(t *testing.T) {
+	request := &source.ReleaseRequest{
+		TagName:            "v0.0.2",
+		PluginName:         "my-awesome-plugin",
+		PluginReleaseActor: "release-actor",
+	}
+
+	r := New("token")
+	assert.Equal(t, "hey krew-index team,\n\nI am [krew-release-bot](https://github.com/rajatjindal/krew-release-bot), and I would like to open this PR to publish version `v0.0.2` of `my-awesome-plugin` on behalf of [release-actor](https://github.com/release-actor).\n\nThanks,\n[krew-release-bot](https://github.com/rajatjindal/krew-release-bot)", *r.getPRBody(request))
+
+	r = &Releaser{TokenUserHandle: "foo-bar", TokenUsername: "Foo Bar", Standalone: true}
+	assert.Equal(t, "hey krew-index team,\n\nI would like to open this PR to publish version `v0.0.2` of `my-awesome-plugin`, released by [release-actor](https://github.com/release-actor).\n\nThis PR was opened by [foo-bar](https://github.com/foo-bar) using [krew-release-bot](https://github.com/rajatjindal/krew-release-bot) in standalone mode.\n\nThanks,\n[Foo Bar](https://github.com/foo-bar)", *r.getPRBody(request))
+
+	request.SignatureVerification = "- https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64.tar.gz: :white_check_mark: verified (verified with public key)"
+	assert.Equal(t, "hey krew-index team,\n\nI would like to open this PR to publish version `v0.0.2` of `my-awesome-plugin`, released by [release-actor](https://github.com/release-actor).\n\nThis PR was opened by [foo-bar](https://github.com/foo-bar) using [krew-release-bot](https://github.com/rajatjindal/krew-release-bot) in standalone mode.\n\nThanks,\n[Foo Bar](https://github.com/foo-bar)\n\n**Signature verification**\n- https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64.tar.gz: :white_check_mark: verified (verified with public key)", *r.getPRBody(request))
+}
@@ -7,9 +7,11 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/rajatjindal/krew-release-bot/pkg/assets"
 	"github.com/rajatjindal/krew-release-bot/pkg/krew"
 	"github.com/rajatjindal/krew-release-bot/pkg/source"
 	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
 )
 
 //Release releases
@@ -23,7 +25,7 @@ func (releaser *Releaser) Release(request *source.ReleaseRequest) (string, error
 	logrus.Infof("will operate in tempdir %s", tempdir)
 	repo, err := releaser.cloneRepos(tempdir, request)
 	if err != nil {
-		return "", err
+		return "", &ReleaseError{Code: source.ErrorCodeUpstreamError, Err: err}
 	}
 
 	newIndexFile, err := ioutil.TempFile("", "krew-")
@@ -42,16 +44,53 @@ func (releaser *Releaser) Release(request *source.ReleaseRequest) (string, error
 	err = krew.ValidateOwnership(existingIndexFile, request.PluginOwner)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", fmt.Errorf("plugin %q not found in existing repo. The first release of a new plugin has to be done manually", request.PluginName)
+			return "", newReleaseError(source.ErrorCodePluginNotFound, "plugin %q not found in existing repo. The first release of a new plugin has to be done manually", request.PluginName)
 		}
 
-		return "", fmt.Errorf("failed when validating ownership with error: %s", err.Error())
+		return "", newReleaseError(source.ErrorCodeOwnershipMismatch, "failed when validating ownership with error: %s", err.Error())
 	}
 
 	logrus.Info("update plugin manifest with latest release info")
 	err = krew.ValidatePlugin(request.PluginName, newIndexFile.Name())
 	if err != nil {
-		return "", fmt.Errorf("failed when validating plugin spec with error: %s", err.Error())
+		return "", newReleaseError(source.ErrorCodeInvalidManifest, "failed when validating plugin spec with error: %s", err.Error())
+	}
+
+	policy := releaser.PolicyFile.Policy()
+
+	logrus.Info("validating changes to protected fields")
+	changes, err := krew.ProtectedFieldChanges(existingIndexFile, newIndexFile.Name())
+	if err != nil {
+		return "", &ReleaseError{Code: source.ErrorCodeInvalidManifest, Err: err}
+	}
+
+	err = policy.ApproveChanges(request.PluginName, changes)
+	if err != nil {
+		return "", &ReleaseError{Code: source.ErrorCodeProtectedFieldChanged, Err: err}
+	}
+
+	logrus.Info("validating platform uris")
+	plugin, err := indexscanner.ReadPluginFile(newIndexFile.Name())
+	if err != nil {
+		return "", &ReleaseError{Code: source.ErrorCodeInvalidManifest, Err: err}
+	}
+
+	err = policy.ValidateURIs(request, &plugin)
+	if err != nil {
+		return "", newReleaseError(source.ErrorCodeURINotAllowed, "failed when validating platform uris with error: %s", err.Error())
+	}
+
+	logrus.Info("validating plugin version")
+	err = policy.ValidateVersion(request, &plugin)
+	if err != nil {
+		return "", &ReleaseError{Code: source.ErrorCodeVersionMismatch, Err: err}
+	}
+
+	//the archives are verified after the uris are validated, so that only allowed uris are downloaded
+	logrus.Info("verifying release assets")
+	err = assets.Verify(plugin)
+	if err != nil {
+		return "", &ReleaseError{Code: source.ErrorCodeInvalidAssets, Err: err}
 	}
 
 	_, err = copyFile(newIndexFile.Name(), existingIndexFile)
@@ -67,13 +106,13 @@ func (releaser *Releaser) Release(request *source.ReleaseRequest) (string, error
 
 	err = releaser.addCommitAndPush(repo, commit, request)
 	if err != nil {
-		return "", err
+		return "", &ReleaseError{Code: source.ErrorCodeUpstreamError, Err: err}
 	}
 
 	logrus.Info("submitting the pr")
 	pr, err := releaser.submitPR(request)
 	if err != nil {
-		return "", err
+		return "", &ReleaseError{Code: source.ErrorCodeUpstreamError, Err: err}
 	}
 
 	return pr, nil
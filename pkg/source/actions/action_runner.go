@@ -3,40 +3,63 @@ package actions
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v29/github"
+	"github.com/rajatjindal/krew-release-bot/pkg/client"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
 	"github.com/rajatjindal/krew-release-bot/pkg/source"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
+	"sigs.k8s.io/krew/pkg/index/validation"
 )
 
-//RunAction runs the github action
-func RunAction() error {
-	client := github.NewClient(nil)
+//RunAction releases the plugin using the config, read from the github action using ConfigFromAction,
+//or filled by the cli
+func RunAction(config *Config) error {
+	client := newGithubClient(config.Token)
 
-	tag, err := getTag()
-	if err != nil {
-		return err
+	releaseRequest := &source.ReleaseRequest{
+		TagName:            config.Tag,
+		PluginOwner:        config.Owner,
+		PluginRepo:         config.Repo,
+		PluginReleaseActor: config.Actor,
 	}
 
-	owner, repo, err := getOwnerAndRepo()
-	if err != nil {
-		return err
+	var err error
+	if config.TagPattern != "" {
+		match, err := source.MatchTag(config.TagPattern, config.Tag)
+		if err != nil {
+			return err
+		}
+
+		releaseRequest.PluginName = match.Plugin
+		releaseRequest.Version = match.Version
+	} else if config.TagPrefix != "" {
+		releaseRequest.Version, err = source.VersionForTag(config.Tag, config.TagPrefix)
+		if err != nil {
+			return err
+		}
 	}
 
-	actor, err := getActionActor()
-	if err != nil {
-		return err
+	templateFile := config.templateFile(releaseRequest.PluginName)
+	logrus.Infof("using template file %q", templateFile)
+	releaseRequest.TemplateFile = templateFile
+
+	//the manifest is generated, when no template file is found
+	generate := false
+	if _, err := os.Stat(templateFile); os.IsNotExist(err) && config.TemplateFile == "" {
+		generate = true
 	}
 
-	releaseInfo, err := getReleaseForTag(client, tag)
+	endGroup := config.startGroup("wait for release assets")
+	releaseInfo, err := waitForReleaseAssets(client, config, releaseRequest, generate)
+	endGroup()
 	if err != nil {
 		return err
 	}
@@ -45,32 +68,127 @@ func RunAction() error {
 		return fmt.Errorf("release with tag %q is a pre-release. skipping", releaseInfo.GetTagName())
 	}
 
-	templateFile := getTemplateFile()
-	logrus.Infof("using template file %q", templateFile)
+	repoInfo, _, err := client.Repositories.Get(context.TODO(), config.Owner, config.Repo)
+	if err != nil {
+		return err
+	}
 
-	releaseRequest := &source.ReleaseRequest{
-		TagName:            releaseInfo.GetTagName(),
-		PluginOwner:        owner,
-		PluginRepo:         repo,
-		PluginReleaseActor: actor,
-		TemplateFile:       templateFile,
+	//annotations are added on the template, unless the manifest is generated
+	annotatedFile := templateFile
+	if generate {
+		annotatedFile = ""
 	}
 
-	pluginName, pluginManifest, err := source.ProcessTemplate(templateFile, releaseRequest)
+	endGroup = config.startGroup("render manifest")
+	pluginName, pluginManifest, err := renderManifest(client, config, releaseRequest, releaseInfo, repoInfo, generate)
+	endGroup()
 	if err != nil {
+		config.annotateError(annotatedFile, err)
+		return err
+	}
+
+	endGroup = config.startGroup("validate manifest")
+	plugin, err := validateManifest(config, releaseRequest, pluginName, pluginManifest)
+	endGroup()
+	if err != nil {
+		config.annotateError(annotatedFile, err)
 		return err
 	}
 
 	releaseRequest.PluginName = pluginName
 	releaseRequest.ProcessedTemplate = pluginManifest
 
-	pr, err := submitForPR(releaseRequest)
+	manifestPath, err := saveManifest(config.Workspace, pluginName, pluginManifest)
+	if err != nil {
+		return err
+	}
+
+	endGroup = config.startGroup("submit release")
+	pr, err := submitRelease(config, releaseRequest)
+	endGroup()
 	if err != nil {
+		config.annotateError("", err)
 		return err
 	}
 
 	logrus.Info(pr)
-	return nil
+	if !config.GithubActions {
+		logrus.Infof("rendered manifest saved at %s", manifestPath)
+		return nil
+	}
+
+	prURL := getPRURL(pr)
+	err = writeOutputs(map[string]string{
+		"pr-url":        prURL,
+		"plugin-name":   pluginName,
+		"version":       plugin.Spec.Version,
+		"manifest-path": manifestPath,
+	})
+	if err != nil {
+		return err
+	}
+
+	//signatures are verified by the action, so the result is added to the PR only when running standalone
+	signatures := ""
+	if config.Signatures != nil {
+		signatures = config.Signatures.Summary()
+	}
+
+	return writeStepSummary(plugin, prURL, signatures)
+}
+
+//waitForReleaseAssets waits for the release, and the assets referenced in the template, to be uploaded
+func waitForReleaseAssets(client *github.Client, config *Config, request *source.ReleaseRequest, generate bool) (*github.RepositoryRelease, error) {
+	assetNames := []string{}
+	if !generate {
+		var err error
+		assetNames, err = getReferencedAssets(request.TemplateFile, request.TagName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return waitForRelease(client, request.PluginOwner, request.PluginRepo, request.TagName, config.Release, assetNames, config.WaitTimeout)
+}
+
+//renderManifest renders the template file, or generates the manifest from release assets
+func renderManifest(client *github.Client, config *Config, request *source.ReleaseRequest, release *github.RepositoryRelease, repo *github.Repository, generate bool) (string, []byte, error) {
+	if generate {
+		logrus.Infof("template file %q not found, generating manifest from release assets", request.TemplateFile)
+		return generateManifest(client, config, request, release, repo)
+	}
+
+	templateContext, err := getTemplateContext(client, request, release, repo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return source.ProcessTemplate(request.TemplateFile, templateContext, source.TemplateOptions{
+		Workdir:      config.workdir(),
+		EnvAllowlist: config.TemplateEnvAllowlist,
+		Strict:       config.Strict,
+		Signatures:   config.Signatures,
+	})
+}
+
+//validateManifest validates the rendered manifest, and the release assets it refers to
+func validateManifest(config *Config, request *source.ReleaseRequest, pluginName string, manifest []byte) (index.Plugin, error) {
+	plugin, err := indexscanner.DecodePluginFile(bytes.NewReader(manifest))
+	if err != nil {
+		return index.Plugin{}, err
+	}
+
+	err = validation.ValidatePlugin(pluginName, plugin)
+	if err != nil {
+		return index.Plugin{}, fmt.Errorf("failed when validating plugin spec with error: %s", err.Error())
+	}
+
+	err = source.ValidateVersion(plugin.Spec.Version, request.TagName, config.TagPattern)
+	if err != nil {
+		return index.Plugin{}, err
+	}
+
+	return plugin, nil
 }
 
 func getTag() (string, error) {
@@ -87,18 +205,26 @@ func getTag() (string, error) {
 	return strings.ReplaceAll(ref, "refs/tags/", ""), nil
 }
 
-func getReleaseForTag(client *github.Client, tag string) (*github.RepositoryRelease, error) {
-	owner, repo, err := getOwnerAndRepo()
-	if err != nil {
-		return nil, err
+//newGithubClient creates the github client authenticated with the token.
+//Draft releases are visible only to authenticated clients with push access to the repo
+func newGithubClient(token string) *github.Client {
+	if token == "" {
+		logrus.Warn("no github token found, draft releases cannot be seen while waiting for the release")
+		return github.NewClient(nil)
 	}
 
-	release, _, err := client.Repositories.GetReleaseByTag(context.TODO(), owner, repo, tag)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	return github.NewClient(oauth2.NewClient(context.TODO(), ts))
+}
+
+//getTemplateContext gets the release and repo info made available to the template
+func getTemplateContext(client *github.Client, request *source.ReleaseRequest, release *github.RepositoryRelease, repo *github.Repository) (*source.TemplateContext, error) {
+	sha, _, err := client.Repositories.GetCommitSHA1(context.TODO(), request.PluginOwner, request.PluginRepo, request.TagName, "")
 	if err != nil {
 		return nil, err
 	}
 
-	return release, nil
+	return source.NewTemplateContext(request, release, repo, sha), nil
 }
 
 //getOwnerAndRepo gets the owner and repo from the env
@@ -126,68 +252,61 @@ func getActionActor() (string, error) {
 	return actor, nil
 }
 
-func submitForPR(request *source.ReleaseRequest) (string, error) {
-	body, err := json.Marshal(request)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, getWebhookURL(), bytes.NewBuffer(body))
-	if err != nil {
-		return "", err
+//submitRelease opens the PR in krew-index directly when running standalone,
+//otherwise submits the release to krew-release-bot webhook
+func submitRelease(config *Config, request *source.ReleaseRequest) (string, error) {
+	if !config.Standalone {
+		return submitForPR(config, request)
 	}
 
-	req.Header.Add("content-type", "application/json")
-
-	client := http.Client{
-		Timeout: time.Duration(30 * time.Second),
+	if config.GithubToken == "" {
+		return "", fmt.Errorf("input github_token is required when running standalone")
 	}
 
-	resp, err := client.Do(req)
+	r, err := releaser.NewForUser(config.GithubToken, config.KrewIndexFork)
 	if err != nil {
 		return "", err
 	}
 
-	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if config.Policy != nil {
+		r.PolicyFile = releaser.StaticPolicy(config.Policy)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("expected status code %d got %d. body: %s", http.StatusOK, resp.StatusCode, string(respBody))
+	//the signatures are verified by this action, so the result can be added to the PR it opens
+	if config.Signatures != nil {
+		request.SignatureVerification = config.Signatures.Summary()
 	}
 
-	return string(respBody), nil
+	logrus.Infof("running standalone, opening pr from %s/%s", r.LocalKrewIndexRepoOwner, r.LocalKrewIndexRepo)
+	return r.Release(request)
 }
 
-func getWebhookURL() string {
-	if os.Getenv("KREW_RELEASE_BOT_WEBHOOK_URL") != "" {
-		return os.Getenv("KREW_RELEASE_BOT_WEBHOOK_URL")
-	}
+//submitRetries is the number of times a request failing with a retryable error is sent again
+var submitRetries = 4
 
-	return "https://krew-release-bot.rajatjindal.com/github-action-webhook"
-}
+//submitRetryDelay is the time to wait before sending the request again the first time.
+//It doubles with every retry
+var submitRetryDelay = 2 * time.Second
 
-//getInputForAction gets input to action
-func getInputForAction(key string) string {
-	return os.Getenv(fmt.Sprintf("INPUT_%s", strings.ToUpper(key)))
-}
+func submitForPR(config *Config, request *source.ReleaseRequest) (string, error) {
+	opts := []client.Option{client.WithRetries(submitRetries, submitRetryDelay)}
 
-func getWorkDirectory() string {
-	workdirInput := getInputForAction("workdir")
-	if workdirInput != "" {
-		return workdirInput
+	token, err := getOIDCToken(config.OIDCAudience)
+	if err != nil {
+		return "", err
 	}
 
-	return os.Getenv("GITHUB_WORKSPACE")
-}
+	if token != "" {
+		opts = append(opts, client.WithBearerToken(token))
+	}
 
-func getTemplateFile() string {
-	templateFile := getInputForAction("krew_template_file")
-	if templateFile != "" {
-		return filepath.Join(getWorkDirectory(), templateFile)
+	c := client.New(config.WebhookURL, opts...)
+
+	response, err := c.Submit(context.TODO(), request)
+	if err != nil {
+		return "", err
 	}
 
-	return filepath.Join(getWorkDirectory(), ".krew.yaml")
+	return response.PRURL, nil
 }
+
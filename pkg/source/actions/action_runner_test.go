@@ -1,12 +1,18 @@
 package actions
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
+	"sigs.k8s.io/yaml"
 )
 
 func TestGetOwnerAndRepo(t *testing.T) {
@@ -151,6 +157,62 @@ func assertError(t *testing.T, expectedError string, err error) {
 	}
 }
 
+func TestGetTemplateFile(t *testing.T) {
+	testcases := []struct {
+		name                 string
+		setup                func()
+		plugin               string
+		expectedTemplateFile string
+	}{
+		{
+			name:                 "default template file",
+			expectedTemplateFile: "data/.krew.yaml",
+		},
+		{
+			name: "template file from input",
+			setup: func() {
+				os.Setenv("INPUT_KREW_TEMPLATE_FILE", "templates/plugin.yaml")
+			},
+			expectedTemplateFile: "data/templates/plugin.yaml",
+		},
+		{
+			name: "template file relative to workdir",
+			setup: func() {
+				os.Setenv("INPUT_WORKDIR", "./data/plugins/")
+			},
+			expectedTemplateFile: "data/plugins/.krew.yaml",
+		},
+		{
+			name:                 "plugin matched from tag",
+			plugin:               "foo",
+			expectedTemplateFile: "data/.krew/foo.yaml",
+		},
+		{
+			name: "plugin matched from tag, with placeholder in template file input",
+			setup: func() {
+				os.Setenv("INPUT_KREW_TEMPLATE_FILE", "plugins/{plugin}/.krew.yaml")
+			},
+			plugin:               "foo",
+			expectedTemplateFile: "data/plugins/foo/.krew.yaml",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+			setupEnvironment()
+
+			if tc.setup != nil {
+				tc.setup()
+			}
+
+			config, err := ConfigFromAction()
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedTemplateFile, config.templateFile(tc.plugin))
+		})
+	}
+}
+
 func TestRunAction(t *testing.T) {
 	testcases := []struct {
 		name          string
@@ -172,8 +234,13 @@ func TestRunAction(t *testing.T) {
 					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
 					Reply(404).
 					BodyString("no release with tag v0.0.2 found")
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases").
+					Reply(200).
+					BodyString(`[]`)
 			},
-			expectedError: "GET https://api.github.com/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2: 404  []",
+			expectedError: `release with tag "v0.0.2" not found`,
 		},
 		{
 			name: "owner and repo not found",
@@ -207,7 +274,7 @@ func TestRunAction(t *testing.T) {
 					Reply(200).
 					BodyString(releaseNoAssets)
 			},
-			expectedError: `no assets found for release with tag "v0.0.2"`,
+			expectedError: `asset darwin-amd64-v0.0.2.tar.gz referenced in the template not found in release with tag "v0.0.2"`,
 		},
 		{
 			name: "release have assets, but downloading them fails",
@@ -217,6 +284,16 @@ func TestRunAction(t *testing.T) {
 					Reply(200).
 					BodyString(releaseWithAssets)
 
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin").
+					Reply(200).
+					BodyString(repoInfo)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v0.0.2").
+					Reply(200).
+					BodyString("6dcb09b5b57875f334f61aebed695e2e4193db5e")
+
 				gock.New("https://github.com").
 					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz").
 					Reply(404).
@@ -237,15 +314,128 @@ func TestRunAction(t *testing.T) {
 					Reply(200).
 					BodyString(releaseWithAssets)
 
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin").
+					Reply(200).
+					BodyString(repoInfo)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v0.0.2").
+					Reply(200).
+					BodyString("6dcb09b5b57875f334f61aebed695e2e4193db5e")
+
 				gock.New("https://github.com").
 					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz").
 					Reply(200).
-					BodyString("darwin-amd64-v0.0.2.tar.gz")
+					File("data/assets/darwin-amd64.tar.gz")
 
 				gock.New("https://github.com").
 					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz").
 					Reply(200).
-					BodyString("linux-amd64")
+					File("data/assets/linux-amd64.tar.gz")
+
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(200).
+					JSON("PR https://github.com/kubernetes-sigs/krew-index/pull/26 opened successfully")
+			},
+		},
+		{
+			name: "release have assets, but webhook fails verifying them",
+			setup: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Reply(200).
+					BodyString(releaseWithAssets)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin").
+					Reply(200).
+					BodyString(repoInfo)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v0.0.2").
+					Reply(200).
+					BodyString("6dcb09b5b57875f334f61aebed695e2e4193db5e")
+
+				gock.New("https://github.com").
+					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz").
+					Reply(200).
+					File("data/assets/linux-amd64.tar.gz")
+
+				gock.New("https://github.com").
+					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz").
+					Reply(200).
+					File("data/assets/linux-amd64.tar.gz")
+
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(422).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "invalid_assets", "message": "verification of release assets failed for 1 platform(s):\ndarwin/amd64: https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz: bin my-awesome-plugin is built for linux/amd64", "retryable": false}}`)
+			},
+			expectedError: "verification of release assets failed for 1 platform(s):\ndarwin/amd64: https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz: bin my-awesome-plugin is built for linux/amd64 (code: invalid_assets)",
+		},
+		{
+			name: "version in manifest does not match the tag",
+			setup: func() {
+				os.Setenv("GITHUB_WORKSPACE", "./data/hardcoded-version/")
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Reply(200).
+					BodyString(releaseWithAssets)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin").
+					Reply(200).
+					BodyString(repoInfo)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v0.0.2").
+					Reply(200).
+					BodyString("6dcb09b5b57875f334f61aebed695e2e4193db5e")
+
+				gock.New("https://github.com").
+					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz").
+					Reply(200).
+					File("data/assets/darwin-amd64.tar.gz")
+
+				gock.New("https://github.com").
+					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz").
+					Reply(200).
+					File("data/assets/linux-amd64.tar.gz")
+			},
+			expectedError: `spec.version "v0.0.1" in the plugin manifest does not match version "v0.0.2" of the released tag "v0.0.2"`,
+		},
+		{
+			name: "no template file, manifest is generated",
+			setup: func() {
+				os.Setenv("GITHUB_WORKSPACE", "./data/no-template/")
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Reply(200).
+					BodyString(releaseWithAssets)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin").
+					Reply(200).
+					BodyString(repoInfo)
+
+				gock.New("https://api.github.com").
+					Get("/repos/kubernetes-sigs/krew-index/contents/plugins/my-awesome-plugin.yaml").
+					Reply(200).
+					BodyString(existingPluginContent)
+
+				gock.New("https://github.com").
+					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz").
+					Reply(200).
+					File("data/assets/darwin-amd64.tar.gz")
+
+				gock.New("https://github.com").
+					Get("/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz").
+					Reply(200).
+					File("data/assets/linux-amd64.tar.gz")
 
 				gock.New("https://krew-release-bot.rajatjindal.com").
 					Post("/github-action-webhook").
@@ -267,8 +457,14 @@ func TestRunAction(t *testing.T) {
 				tc.setup()
 			}
 
-			err := RunAction()
+			config, err := ConfigFromAction()
+			if err == nil {
+				err = RunAction(config)
+			}
 			assertError(t, tc.expectedError, err)
+
+			//remove the manifest saved in the workspace
+			os.RemoveAll(filepath.Join(os.Getenv("GITHUB_WORKSPACE"), manifestDir))
 			logrus.Error(gock.GetUnmatchedRequests())
 
 			for _, g := range gock.GetUnmatchedRequests() {
@@ -284,7 +480,19 @@ const preRelease = `{
 	"id": 22569944,
 	"tag_name": "v0.0.2",
 	"name": "v0.0.2",
-	"prerelease": true
+	"prerelease": true,
+	"assets": [
+		{
+			"id": 16605457,
+			"name": "darwin-amd64-v0.0.2.tar.gz",
+			"state": "uploaded"
+		},
+		{
+			"id": 16605458,
+			"name": "linux-amd64-v0.0.2.tar.gz",
+			"state": "uploaded"
+		}
+	]
 }`
 
 const releaseWithAssets = `{
@@ -296,12 +504,16 @@ const releaseWithAssets = `{
 		{
 			"id": 16605457,
 			"node_id": "MDEyOlJlbGVhc2VBc3NldDE2NjA1NDU3",
-			"name": "darwin-amd64-v0.0.2.tar.gz"
+			"name": "darwin-amd64-v0.0.2.tar.gz",
+			"state": "uploaded",
+			"browser_download_url": "https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2/darwin-amd64-v0.0.2.tar.gz"
 		},
 		{
 			"id": 16605458,
 			"node_id": "MDEyOlJlbGVhc2VBc3NldDE2NjA1NDU3",
-			"name": "linux-amd64-v0.0.2.tar.gz"
+			"name": "linux-amd64-v0.0.2.tar.gz",
+			"state": "uploaded",
+			"browser_download_url": "https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz"
 		}
 	]
 }`
@@ -314,9 +526,173 @@ const releaseNoAssets = `{
 	"assets": []
 }`
 
+const repoInfo = `{
+	"id": 1296269,
+	"name": "my-awesome-plugin",
+	"full_name": "foo-bar/my-awesome-plugin",
+	"html_url": "https://github.com/foo-bar/my-awesome-plugin",
+	"description": "This plugin show what an awesome plugin looks like"
+}`
+
+const existingPluginContent = `{
+	"type": "file",
+	"encoding": "base64",
+	"name": "my-awesome-plugin.yaml",
+	"path": "plugins/my-awesome-plugin.yaml",
+	"content": "YXBpVmVyc2lvbjoga3Jldy5nb29nbGVjb250YWluZXJ0b29scy5naXRodWIuY29tL3YxYWxwaGEyCmtpbmQ6IFBsdWdpbgptZXRhZGF0YToKICBuYW1lOiBteS1hd2Vzb21lLXBsdWdpbgpzcGVjOgogIHZlcnNpb246IHYwLjAuMQogIGhvbWVwYWdlOiBodHRwczovL2dpdGh1Yi5jb20vZm9vLWJhci9teS1hd2Vzb21lLXBsdWdpbgogIHNob3J0RGVzY3JpcHRpb246IFRoaXMgaXMgdGhlIG1vc3QgYXdlc29tZSBrdWJlY3RsIHBsdWdpbgogIHBsYXRmb3JtczoKICAtIHNlbGVjdG9yOgogICAgICBtYXRjaExhYmVsczoKICAgICAgICBvczogZGFyd2luCiAgICAgICAgYXJjaDogYW1kNjQKICAgIHVyaTogaHR0cHM6Ly9naXRodWIuY29tL2Zvby1iYXIvbXktYXdlc29tZS1wbHVnaW4vcmVsZWFzZXMvZG93bmxvYWQvdjAuMC4xL2Rhcndpbi1hbWQ2NC12MC4wLjEudGFyLmd6CiAgICBzaGEyNTY6IGFiYjM1YzYxNjQyMWFmNzIxOThhZDdjMmFlZWVmMzg1MTZmMDhmNmE3YWZiMmE3MjhjZjAwNjhhOGE3MTJkZGMKICAgIGJpbjogbXktYXdlc29tZS1wbHVnaW4KICAtIHNlbGVjdG9yOgogICAgICBtYXRjaExhYmVsczoKICAgICAgICBvczogbGludXgKICAgICAgICBhcmNoOiBhbWQ2NAogICAgdXJpOiBodHRwczovL2dpdGh1Yi5jb20vZm9vLWJhci9teS1hd2Vzb21lLXBsdWdpbi9yZWxlYXNlcy9kb3dubG9hZC92MC4wLjEvbGludXgtYW1kNjQtdjAuMC4xLnRhci5negogICAgc2hhMjU2OiBhYmIzNWM2MTY0MjFhZjcyMTk4YWQ3YzJhZWVlZjM4NTE2ZjA4ZjZhN2FmYjJhNzI4Y2YwMDY4YThhNzEyZGRjCiAgICBiaW46IG15LWF3ZXNvbWUtcGx1Z2luCg=="
+}`
+
 func setupEnvironment() {
 	os.Setenv("GITHUB_REPOSITORY", "foo-bar/my-awesome-plugin")
 	os.Setenv("GITHUB_ACTOR", "karthik-aryan")
 	os.Setenv("GITHUB_REF", "refs/tags/v0.0.2")
 	os.Setenv("GITHUB_WORKSPACE", "./data/")
 }
+
+//TestValidateManifestVersion validates the versions using the cases the server is tested with,
+//so that a release passing validation in the action is not rejected by the server
+func TestValidateManifestVersion(t *testing.T) {
+	data, err := ioutil.ReadFile("../data/versions/versions.yaml")
+	assert.Nil(t, err)
+
+	testcases := []struct {
+		Name          string `json:"name"`
+		Tag           string `json:"tag"`
+		TagPattern    string `json:"tagPattern"`
+		Version       string `json:"version"`
+		SpecVersion   string `json:"specVersion"`
+		ExpectedError string `json:"expectedError"`
+	}{}
+	err = yaml.UnmarshalStrict(data, &testcases)
+	assert.Nil(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			config := &Config{TagPattern: tc.TagPattern}
+			request := &source.ReleaseRequest{TagName: tc.Tag, Version: tc.Version}
+
+			_, err := validateManifest(config, request, "foo", []byte(fmt.Sprintf(versionedManifest, tc.SpecVersion)))
+			assertError(t, tc.ExpectedError, err)
+		})
+	}
+}
+
+const versionedManifest = `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  version: %s
+  homepage: https://github.com/foo-bar/kubectl-foo
+  shortDescription: foo
+  platforms:
+  - selector:
+      matchLabels:
+        os: linux
+        arch: amd64
+    uri: https://github.com/foo-bar/kubectl-foo/releases/download/v0.0.2/linux-amd64.tar.gz
+    sha256: 8b3a8d2ecb3c5d7bd4ea7e4a0e7f2d6c3b1f4ef0b1d4c2b7b4e6b8a4f3c2d1e0
+    bin: kubectl-foo
+`
+
+func TestSubmitForPR(t *testing.T) {
+	submitRetryDelay = time.Millisecond
+	defer func() {
+		submitRetryDelay = 2 * time.Second
+	}()
+
+	testcases := []struct {
+		name          string
+		setupMocks    func()
+		expectedPR    string
+		expectedError string
+	}{
+		{
+			name: "pr submitted",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "plain text response from older webhook",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(200).
+					BodyString(`PR "https://github.com/kubernetes-sigs/krew-index/pull/26" submitted successfully`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "error which is not retryable",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(404).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "plugin_not_found", "message": "plugin \"foo\" not found in existing repo", "retryable": false}}`)
+			},
+			expectedError: `plugin "foo" not found in existing repo (code: plugin_not_found)`,
+		},
+		{
+			name: "retryable error, succeeds on retry",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(502).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "upstream_error", "message": "github is down", "retryable": true}}`)
+
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "network error, succeeds on retry with same idempotency key",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					MatchHeader("Idempotency-Key", source.IdempotencyKey(&source.ReleaseRequest{TagName: "v0.0.2"})).
+					ReplyError(fmt.Errorf("connection reset by peer"))
+
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					MatchHeader("Idempotency-Key", source.IdempotencyKey(&source.ReleaseRequest{TagName: "v0.0.2"})).
+					Reply(200).
+					JSON(`{"apiVersion": "v1", "status": "submitted", "pr_url": "https://github.com/kubernetes-sigs/krew-index/pull/26"}`)
+			},
+			expectedPR: "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		},
+		{
+			name: "retryable error, fails after all retries",
+			setupMocks: func() {
+				gock.New("https://krew-release-bot.rajatjindal.com").
+					Post("/github-action-webhook").
+					Times(5).
+					Reply(502).
+					JSON(`{"apiVersion": "v1", "status": "failed", "error": {"code": "upstream_error", "message": "github is down", "retryable": true}}`)
+			},
+			expectedError: "github is down (code: upstream_error)",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			tc.setupMocks()
+
+			pr, err := submitForPR(&Config{}, &source.ReleaseRequest{TagName: "v0.0.2"})
+			assert.Equal(t, tc.expectedPR, pr)
+			assertError(t, tc.expectedError, err)
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
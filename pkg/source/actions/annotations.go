@@ -0,0 +1,106 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//commandOutput is where workflow commands are written to
+var commandOutput io.Writer = os.Stdout
+
+//templateErrorLine finds the line in errors from parsing and executing the template e.g.
+//template: .krew.yaml:13:6: executing ".krew.yaml" at <...>
+//Errors from the rendered yaml, e.g. yaml: line 5, are not used, as helpers rendering
+//multiple lines make the line of the rendered yaml different from the line of the template
+var templateErrorLine = regexp.MustCompile(`template: ([^:\s]+):(\d+)(?::\d+)?:`)
+
+//startGroup starts a collapsible group in the log of the workflow, and returns the func to end it
+func startGroup(name string) func() {
+	fmt.Fprintf(commandOutput, "::group::%s\n", escapeData(name))
+	return func() {
+		fmt.Fprintln(commandOutput, "::endgroup::")
+	}
+}
+
+//startGroup starts a collapsible group in the log, when running as github action
+func (c *Config) startGroup(name string) func() {
+	if !c.GithubActions {
+		return func() {}
+	}
+
+	return startGroup(name)
+}
+
+//annotateError annotates the file with the error, when running as github action
+func (c *Config) annotateError(file string, err error) {
+	if c.GithubActions {
+		annotateError(file, err)
+	}
+}
+
+//annotateError writes the error as an annotation on the template file, at the line of the template the error
+//refers to if it is known. The file is not annotated when empty e.g. for generated manifests
+func annotateError(templateFile string, err error) {
+	properties := []string{}
+	if templateFile != "" {
+		properties = append(properties, fmt.Sprintf("file=%s", escapeProperty(relativeToWorkspace(templateFile))))
+
+		if line := errorLine(templateFile, err); line > 0 {
+			properties = append(properties, fmt.Sprintf("line=%d", line))
+		}
+	}
+
+	command := "::error"
+	if len(properties) > 0 {
+		command = fmt.Sprintf("::error %s", strings.Join(properties, ","))
+	}
+
+	fmt.Fprintf(commandOutput, "%s::%s\n", command, escapeData(err.Error()))
+}
+
+//errorLine finds the line of the template the error refers to, or 0 if not known
+func errorLine(templateFile string, err error) int {
+	matches := templateErrorLine.FindStringSubmatch(err.Error())
+	if matches == nil || matches[1] != filepath.Base(templateFile) {
+		return 0
+	}
+
+	line, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return 0
+	}
+
+	return line
+}
+
+//relativeToWorkspace returns the path relative to the repo, as expected in annotations
+func relativeToWorkspace(file string) string {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		return file
+	}
+
+	rel, err := filepath.Rel(workspace, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return file
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
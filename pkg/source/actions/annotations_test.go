@@ -0,0 +1,116 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateError(t *testing.T) {
+	testcases := []struct {
+		name               string
+		templateFile       string
+		err                error
+		expectedAnnotation string
+	}{
+		{
+			name:               "error executing template",
+			templateFile:       "/home/runner/work/foo/.krew.yaml",
+			err:                fmt.Errorf(`template: .krew.yaml:13:6: executing ".krew.yaml" at <.TagNmae>: can't evaluate field TagNmae in type *source.TemplateContext`),
+			expectedAnnotation: "::error file=.krew.yaml,line=13::template: .krew.yaml:13:6: executing \".krew.yaml\" at <.TagNmae>: can't evaluate field TagNmae in type *source.TemplateContext\n",
+		},
+		{
+			name:               "error parsing template",
+			templateFile:       "/home/runner/work/foo/.krew.yaml",
+			err:                fmt.Errorf(`template: .krew.yaml:6: function "addURIAndSah" not defined`),
+			expectedAnnotation: "::error file=.krew.yaml,line=6::template: .krew.yaml:6: function \"addURIAndSah\" not defined\n",
+		},
+		{
+			name:               "unrendered placeholders in strict mode",
+			templateFile:       "/home/runner/work/foo/templates/plugin.yaml",
+			err:                fmt.Errorf(`rendered template plugin.yaml has unrendered placeholders: spec.homepage contains "<no value>"`),
+			expectedAnnotation: "::error file=templates/plugin.yaml::rendered template plugin.yaml has unrendered placeholders: spec.homepage contains \"<no value>\"\n",
+		},
+		{
+			name:               "invalid yaml",
+			templateFile:       "/home/runner/work/foo/.krew.yaml",
+			err:                fmt.Errorf("error converting YAML to JSON: yaml: line 5: mapping values are not allowed in this context"),
+			expectedAnnotation: "::error file=.krew.yaml::error converting YAML to JSON: yaml: line 5: mapping values are not allowed in this context\n",
+		},
+		{
+			name:               "error in a template other than the template file",
+			templateFile:       "/home/runner/work/foo/.krew.yaml",
+			err:                fmt.Errorf(`template: helpers:3:4: executing "helpers" at <.Foo>: can't evaluate field Foo in type *source.TemplateContext`),
+			expectedAnnotation: "::error file=.krew.yaml::template: helpers:3:4: executing \"helpers\" at <.Foo>: can't evaluate field Foo in type *source.TemplateContext\n",
+		},
+		{
+			name:               "invalid plugin spec",
+			templateFile:       "/home/runner/work/foo/.krew.yaml",
+			err:                fmt.Errorf("failed when validating plugin spec with error: plugin name \"foo\" must match file name \"bar\""),
+			expectedAnnotation: "::error file=.krew.yaml::failed when validating plugin spec with error: plugin name \"foo\" must match file name \"bar\"\n",
+		},
+		{
+			name:               "error without line",
+			templateFile:       "/home/runner/work/foo/.krew.yaml",
+			err:                fmt.Errorf("verification of release assets failed for 1 platform(s):\ndarwin/amd64: 100%% broken"),
+			expectedAnnotation: "::error file=.krew.yaml::verification of release assets failed for 1 platform(s):%0Adarwin/amd64: 100%25 broken\n",
+		},
+		{
+			name:               "generated manifest",
+			err:                fmt.Errorf("no release assets matched the naming convention"),
+			expectedAnnotation: "::error::no release assets matched the naming convention\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv("GITHUB_WORKSPACE", "/home/runner/work/foo")
+
+			buf := new(bytes.Buffer)
+			commandOutput = buf
+			defer func() {
+				commandOutput = os.Stdout
+			}()
+
+			annotateError(tc.templateFile, tc.err)
+			assert.Equal(t, tc.expectedAnnotation, buf.String())
+		})
+	}
+}
+
+func TestStartGroup(t *testing.T) {
+	buf := new(bytes.Buffer)
+	commandOutput = buf
+	defer func() {
+		commandOutput = os.Stdout
+	}()
+
+	endGroup := startGroup("render manifest")
+	endGroup()
+
+	assert.Equal(t, "::group::render manifest\n::endgroup::\n", buf.String())
+}
+
+func TestWorkflowCommandsOnlyInGithubActions(t *testing.T) {
+	buf := new(bytes.Buffer)
+	commandOutput = buf
+	defer func() {
+		commandOutput = os.Stdout
+	}()
+
+	config := &Config{}
+	endGroup := config.startGroup("render manifest")
+	endGroup()
+	config.annotateError("/home/runner/work/foo/.krew.yaml", fmt.Errorf("something went wrong"))
+	assert.Equal(t, "", buf.String())
+
+	config.GithubActions = true
+	endGroup = config.startGroup("render manifest")
+	endGroup()
+	config.annotateError("", fmt.Errorf("something went wrong"))
+	assert.Equal(t, "::group::render manifest\n::endgroup::\n::error::something went wrong\n", buf.String())
+}
@@ -0,0 +1,197 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+)
+
+//Config is the configuration of the release. It is read from the inputs and env of the github action
+//using ConfigFromAction, or filled by the cli when releasing from other CI systems
+type Config struct {
+	//Tag is the tag to release e.g. v1.2.3
+	Tag string
+
+	//Release is the release from the payload of the event, if available. It is fetched using the tag otherwise
+	Release *github.RepositoryRelease
+
+	Owner string
+	Repo  string
+	Actor string
+
+	//Workspace is the directory the plugin repo is checked out in. The rendered manifest is saved in it
+	Workspace string
+
+	//Workdir is the directory the template file is relative to. defaults to Workspace
+	Workdir string
+
+	TemplateFile         string
+	PluginName           string
+	TemplateEnvAllowlist []string
+	Strict               bool
+	TagPrefix            string
+	TagPattern           string
+	WaitTimeout          time.Duration
+
+	//Token is used to read the release, including drafts, from github api
+	Token string
+
+	//WebhookURL is the url of krew-release-bot webhook. defaults to client.DefaultWebhookURL
+	WebhookURL   string
+	OIDCAudience string
+
+	//Standalone opens the PR in krew-index directly, using GithubToken and KrewIndexFork
+	Standalone    bool
+	GithubToken   string
+	KrewIndexFork string
+
+	//Signatures verifies cosign signatures of the archives, if set
+	Signatures *source.SignatureVerifier
+
+	//Policy is applied to the plugin manifest when running standalone, like the policy
+	//file of the krew-release-bot server
+	Policy *releaser.Policy
+
+	//GithubActions is true when running as github action. Workflow commands, outputs and
+	//the job summary are written only then, as other CI systems do not understand them
+	GithubActions bool
+}
+
+//ConfigFromAction reads the config from the inputs and env of the github action
+func ConfigFromAction() (*Config, error) {
+	tag, release, err := getTagAndRelease()
+	if err != nil {
+		return nil, err
+	}
+
+	owner, repo, err := getOwnerAndRepo()
+	if err != nil {
+		return nil, err
+	}
+
+	actor, err := getActionActor()
+	if err != nil {
+		return nil, err
+	}
+
+	waitTimeout, err := getWaitTimeout()
+	if err != nil {
+		return nil, err
+	}
+
+	token := getInputForAction("token")
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+
+	policy, err := getPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{
+		Tag:                  tag,
+		Release:              release,
+		Owner:                owner,
+		Repo:                 repo,
+		Actor:                actor,
+		Workspace:            os.Getenv("GITHUB_WORKSPACE"),
+		Workdir:              getInputForAction("workdir"),
+		TemplateFile:         getInputForAction("krew_template_file"),
+		PluginName:           getInputForAction("plugin_name"),
+		TemplateEnvAllowlist: getEnvAllowlist(),
+		Strict:               getInputForAction("strict") == "true",
+		TagPrefix:            getInputForAction("tag_prefix"),
+		TagPattern:           getInputForAction("tag_pattern"),
+		WaitTimeout:          waitTimeout,
+		Token:                token,
+		WebhookURL:           os.Getenv("KREW_RELEASE_BOT_WEBHOOK_URL"),
+		OIDCAudience:         getInputForAction("oidc_audience"),
+		Standalone:           getInputForAction("standalone") == "true",
+		GithubToken:          getInputForAction("github_token"),
+		KrewIndexFork:        getInputForAction("krew_index_fork"),
+		Signatures:           getSignatureVerifier(),
+		Policy:               policy,
+		GithubActions:        true,
+	}, nil
+}
+
+//getInputForAction gets input to action
+func getInputForAction(key string) string {
+	return os.Getenv(fmt.Sprintf("INPUT_%s", strings.ToUpper(key)))
+}
+
+//workdir gets the directory the template file is relative to
+func (c *Config) workdir() string {
+	if c.Workdir != "" {
+		return c.Workdir
+	}
+
+	return c.Workspace
+}
+
+//templateFile gets the template file. For monorepos, where the plugin is matched from the tag,
+//{plugin} in the template file is replaced with the plugin name, and it defaults to .krew/<plugin>.yaml
+func (c *Config) templateFile(plugin string) string {
+	if c.TemplateFile != "" {
+		return filepath.Join(c.workdir(), strings.ReplaceAll(c.TemplateFile, "{plugin}", plugin))
+	}
+
+	if plugin != "" {
+		return filepath.Join(c.workdir(), ".krew", fmt.Sprintf("%s.yaml", plugin))
+	}
+
+	return filepath.Join(c.workdir(), ".krew.yaml")
+}
+
+//getEnvAllowlist gets the env variables that the template is allowed to read
+func getEnvAllowlist() []string {
+	allowlist := []string{}
+	for _, name := range strings.Split(getInputForAction("template_env_allowlist"), ",") {
+		if strings.TrimSpace(name) != "" {
+			allowlist = append(allowlist, strings.TrimSpace(name))
+		}
+	}
+
+	return allowlist
+}
+
+//getPolicy gets the policy applied when running standalone. It returns nil if no policy is set
+func getPolicy() (*releaser.Policy, error) {
+	input := getInputForAction("policy")
+	if input == "" {
+		return nil, nil
+	}
+
+	policy, err := releaser.ParsePolicy([]byte(input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input policy. error: %v", err)
+	}
+
+	return policy, nil
+}
+
+//getSignatureVerifier gets the verifier for cosign signatures of the archives.
+//It returns nil if signature verification is not configured
+func getSignatureVerifier() *source.SignatureVerifier {
+	verifier := &source.SignatureVerifier{
+		PublicKey:             []byte(getInputForAction("cosign_public_key")),
+		CertificateIdentity:   getInputForAction("cosign_certificate_identity"),
+		CertificateOIDCIssuer: getInputForAction("cosign_certificate_oidc_issuer"),
+		CertificateRoots:      []byte(getInputForAction("cosign_certificate_roots")),
+		RekorPublicKey:        []byte(getInputForAction("rekor_public_key")),
+		Required:              getInputForAction("require_signatures") == "true",
+	}
+
+	if len(verifier.PublicKey) == 0 && verifier.CertificateIdentity == "" && !verifier.Required {
+		return nil
+	}
+
+	return verifier
+}
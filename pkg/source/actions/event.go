@@ -0,0 +1,79 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/sirupsen/logrus"
+)
+
+//githubEvent is the part of the payload of the event that triggered the action, used to find the tag to release
+type githubEvent struct {
+	Release *github.RepositoryRelease `json:"release"`
+	Inputs  map[string]string         `json:"inputs"`
+}
+
+//getTagAndRelease gets the tag to release from the event that triggered the action.
+//The release is returned as well when it is available in the event payload, otherwise it is nil
+func getTagAndRelease() (string, *github.RepositoryRelease, error) {
+	//allows re-running for an older tag
+	if tag := getInputForAction("tag"); tag != "" {
+		return tag, nil, nil
+	}
+
+	eventName := os.Getenv("GITHUB_EVENT_NAME")
+	switch eventName {
+	case "release":
+		event, err := readEvent()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if event.Release == nil || event.Release.GetTagName() == "" {
+			return "", nil, fmt.Errorf("release not found in payload of %s event", eventName)
+		}
+
+		logrus.Infof("using release %q from payload of %s event", event.Release.GetTagName(), eventName)
+		return event.Release.GetTagName(), event.Release, nil
+	case "workflow_dispatch":
+		event, err := readEvent()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if tag := event.Inputs["tag"]; tag != "" {
+			return tag, nil, nil
+		}
+	}
+
+	tag, err := getTag()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tag, nil, nil
+}
+
+//readEvent reads the payload of the event that triggered the action
+func readEvent() (*githubEvent, error) {
+	eventPath := os.Getenv("GITHUB_EVENT_PATH")
+	if eventPath == "" {
+		return nil, fmt.Errorf("env GITHUB_EVENT_PATH not set")
+	}
+
+	data, err := ioutil.ReadFile(eventPath)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &githubEvent{}
+	err = json.Unmarshal(data, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse event payload %s. error: %v", eventPath, err)
+	}
+
+	return event, nil
+}
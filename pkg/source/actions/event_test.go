@@ -0,0 +1,108 @@
+package actions
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTagAndRelease(t *testing.T) {
+	testcases := []struct {
+		name               string
+		setup              func()
+		expectedTag        string
+		expectedReleaseTag string
+		expectedError      string
+	}{
+		{
+			name: "push tag event",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "push")
+				os.Setenv("GITHUB_REF", "refs/tags/v0.0.2")
+			},
+			expectedTag: "v0.0.2",
+		},
+		{
+			name: "push event for a branch",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "push")
+				os.Setenv("GITHUB_REF", "refs/heads/master")
+			},
+			expectedError: `GITHUB_REF expected to be of format refs/tags/<tag> but found "refs/heads/master"`,
+		},
+		{
+			name: "release event",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "release")
+				os.Setenv("GITHUB_EVENT_PATH", "data/events/release.json")
+				os.Setenv("GITHUB_REF", "refs/tags/v0.0.2")
+			},
+			expectedTag:        "v0.0.2",
+			expectedReleaseTag: "v0.0.2",
+		},
+		{
+			name: "release event without payload",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "release")
+			},
+			expectedError: "env GITHUB_EVENT_PATH not set",
+		},
+		{
+			name: "release event with payload of another event",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "release")
+				os.Setenv("GITHUB_EVENT_PATH", "data/events/workflow_dispatch.json")
+			},
+			expectedError: "release not found in payload of release event",
+		},
+		{
+			name: "workflow_dispatch event with tag input",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "workflow_dispatch")
+				os.Setenv("GITHUB_EVENT_PATH", "data/events/workflow_dispatch.json")
+				os.Setenv("GITHUB_REF", "refs/heads/master")
+			},
+			expectedTag: "v0.0.1",
+		},
+		{
+			name: "workflow_dispatch event on a tag",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "workflow_dispatch")
+				os.Setenv("GITHUB_EVENT_PATH", "data/events/release.json")
+				os.Setenv("GITHUB_REF", "refs/tags/v0.0.2")
+			},
+			expectedTag: "v0.0.2",
+		},
+		{
+			name: "tag input of the action",
+			setup: func() {
+				os.Setenv("GITHUB_EVENT_NAME", "release")
+				os.Setenv("GITHUB_EVENT_PATH", "data/events/release.json")
+				os.Setenv("INPUT_TAG", "v0.0.1")
+			},
+			expectedTag: "v0.0.1",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+
+			if tc.setup != nil {
+				tc.setup()
+			}
+
+			tag, release, err := getTagAndRelease()
+			assert.Equal(t, tc.expectedTag, tag)
+			assertError(t, tc.expectedError, err)
+
+			if tc.expectedReleaseTag == "" {
+				assert.Nil(t, release)
+			} else {
+				assert.Equal(t, tc.expectedReleaseTag, release.GetTagName())
+				assert.Len(t, release.Assets, 1)
+			}
+		})
+	}
+}
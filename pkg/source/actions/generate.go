@@ -0,0 +1,116 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/rajatjindal/krew-release-bot/pkg/krew"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
+)
+
+//generateManifest generates the plugin manifest when no template file is available in the repo
+func generateManifest(client *github.Client, config *Config, request *source.ReleaseRequest, release *github.RepositoryRelease, repo *github.Repository) (string, []byte, error) {
+	pluginName := config.pluginName(request)
+	logrus.Infof("generating manifest for plugin %q", pluginName)
+
+	existing, err := getExistingPlugin(client, pluginName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	version := request.Version
+	if version == "" {
+		version, err = source.VersionForTag(request.TagName, "")
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	info := source.ManifestInfo{
+		PluginName:       pluginName,
+		Version:          version,
+		Homepage:         pluginHomepage(existing, repo),
+		ShortDescription: existing.Spec.ShortDescription,
+		Description:      repo.GetDescription(),
+		Caveats:          existing.Spec.Caveats,
+		Existing:         existing,
+		Assets:           map[string]string{},
+		Signatures:       config.Signatures,
+	}
+
+	if info.Description == "" {
+		info.Description = existing.Spec.Description
+	}
+
+	for _, asset := range release.Assets {
+		info.Assets[asset.GetName()] = asset.GetBrowserDownloadURL()
+	}
+
+	manifest, err := source.GenerateManifest(info)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return pluginName, manifest, nil
+}
+
+//pluginHomepage reuses the homepage in krew-index, as changing it needs approval. It falls back to
+//the homepage of the repo, and then to the repo itself
+func pluginHomepage(existing *index.Plugin, repo *github.Repository) string {
+	if existing != nil && existing.Spec.Homepage != "" {
+		return existing.Spec.Homepage
+	}
+
+	if repo.GetHomepage() != "" {
+		return repo.GetHomepage()
+	}
+
+	return repo.GetHTMLURL()
+}
+
+//getExistingPlugin fetches the plugin manifest from upstream krew-index
+func getExistingPlugin(client *github.Client, pluginName string) (*index.Plugin, error) {
+	file := fmt.Sprintf("plugins/%s", krew.PluginFileName(pluginName))
+	content, _, _, err := client.Repositories.GetContents(
+		context.TODO(),
+		krew.GetKrewIndexRepoOwner(),
+		krew.GetKrewIndexRepoName(),
+		file,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from krew-index. The first release of a new plugin has to be done manually. error: %v", file, err)
+	}
+
+	data, err := content.GetContent()
+	if err != nil {
+		return nil, err
+	}
+
+	plugin, err := indexscanner.DecodePluginFile(bytes.NewBufferString(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin, nil
+}
+
+//pluginName gets the plugin name from the config, or from the repo name
+func (c *Config) pluginName(request *source.ReleaseRequest) string {
+	if c.PluginName != "" {
+		return c.PluginName
+	}
+
+	//matched from the tag for monorepos
+	if request.PluginName != "" {
+		return request.PluginName
+	}
+
+	return strings.TrimPrefix(request.PluginRepo, "kubectl-")
+}
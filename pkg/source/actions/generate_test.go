@@ -0,0 +1,43 @@
+package actions
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestPluginHomepage(t *testing.T) {
+	testcases := []struct {
+		name     string
+		existing *index.Plugin
+		repo     *github.Repository
+		expected string
+	}{
+		{
+			name:     "homepage in krew-index",
+			existing: &index.Plugin{Spec: index.PluginSpec{Homepage: "https://foo-bar.dev/docs/"}},
+			repo:     &github.Repository{HTMLURL: github.String("https://github.com/foo-bar/kubectl-foo"), Homepage: github.String("https://foo-bar.dev")},
+			expected: "https://foo-bar.dev/docs/",
+		},
+		{
+			name:     "homepage of repo",
+			existing: &index.Plugin{},
+			repo:     &github.Repository{HTMLURL: github.String("https://github.com/foo-bar/kubectl-foo"), Homepage: github.String("https://foo-bar.dev")},
+			expected: "https://foo-bar.dev",
+		},
+		{
+			name:     "repo url",
+			existing: &index.Plugin{},
+			repo:     &github.Repository{HTMLURL: github.String("https://github.com/foo-bar/kubectl-foo")},
+			expected: "https://github.com/foo-bar/kubectl-foo",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, pluginHomepage(tc.existing, tc.repo))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+//defaultOIDCAudience is the audience of the OIDC token sent to krew-release-bot webhook
+const defaultOIDCAudience = "krew-release-bot"
+
+//getOIDCToken requests the OIDC token of the workflow, to authenticate the release to the webhook.
+//It is empty when the workflow does not have the id-token: write permission, or not running as github action
+func getOIDCToken(audience string) (string, error) {
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", nil
+	}
+
+	if audience == "" {
+		audience = defaultOIDCAudience
+	}
+
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("audience", audience)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", requestToken))
+
+	client := http.Client{
+		Timeout: time.Duration(30 * time.Second),
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting oidc token. expected status code %d got %d. body: %s", http.StatusOK, resp.StatusCode, string(body))
+	}
+
+	token := struct {
+		Value string `json:"value"`
+	}{}
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return "", fmt.Errorf("parsing oidc token response. error: %v", err)
+	}
+
+	return token.Value, nil
+}
@@ -0,0 +1,92 @@
+package actions
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestGetOIDCToken(t *testing.T) {
+	testcases := []struct {
+		name          string
+		env           map[string]string
+		audience      string
+		setupMocks    func()
+		expectedToken string
+		expectedError string
+	}{
+		{
+			name: "id-token permission not granted",
+		},
+		{
+			name: "token with default audience",
+			env: map[string]string{
+				"ACTIONS_ID_TOKEN_REQUEST_URL":   "https://pipelines.actions.githubusercontent.com/token?api-version=2.0",
+				"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token",
+			},
+			setupMocks: func() {
+				gock.New("https://pipelines.actions.githubusercontent.com").
+					Get("/token").
+					MatchParam("api-version", "2.0").
+					MatchParam("audience", "krew-release-bot").
+					MatchHeader("authorization", "Bearer request-token").
+					Reply(200).
+					JSON(`{"value": "oidc-token"}`)
+			},
+			expectedToken: "oidc-token",
+		},
+		{
+			name: "token with audience from config",
+			env: map[string]string{
+				"ACTIONS_ID_TOKEN_REQUEST_URL":   "https://pipelines.actions.githubusercontent.com/token",
+				"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token",
+			},
+			audience: "my-krew-release-bot",
+			setupMocks: func() {
+				gock.New("https://pipelines.actions.githubusercontent.com").
+					Get("/token").
+					MatchParam("audience", "my-krew-release-bot").
+					Reply(200).
+					JSON(`{"value": "oidc-token"}`)
+			},
+			expectedToken: "oidc-token",
+		},
+		{
+			name: "token request fails",
+			env: map[string]string{
+				"ACTIONS_ID_TOKEN_REQUEST_URL":   "https://pipelines.actions.githubusercontent.com/token",
+				"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token",
+			},
+			setupMocks: func() {
+				gock.New("https://pipelines.actions.githubusercontent.com").
+					Get("/token").
+					Reply(403).
+					BodyString("forbidden")
+			},
+			expectedError: "requesting oidc token. expected status code 200 got 403. body: forbidden",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+			}
+
+			if tc.setupMocks != nil {
+				tc.setupMocks()
+			}
+
+			token, err := getOIDCToken(tc.audience)
+			assert.Equal(t, tc.expectedToken, token)
+			assertError(t, tc.expectedError, err)
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package actions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/client"
+	"github.com/rajatjindal/krew-release-bot/pkg/krew"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+//getPRURL gets the url of the PR from the response of the webhook
+func getPRURL(response string) string {
+	return client.PRURL.FindString(response)
+}
+
+//manifestDir is the directory in the workspace where the rendered manifest is saved
+const manifestDir = ".krew-release-bot"
+
+//saveManifest saves the rendered manifest in the workspace, so that it can be used in later steps.
+//The action runs in a container where only the workspace is mounted from the runner, so the
+//path relative to the workspace is returned, which is the working directory of later steps
+func saveManifest(workspace, pluginName string, manifest []byte) (string, error) {
+	dir := filepath.Join(workspace, manifestDir)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, krew.PluginFileName(pluginName)), manifest, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(manifestDir, krew.PluginFileName(pluginName)), nil
+}
+
+//writeOutputs writes the outputs of the action to $GITHUB_OUTPUT
+func writeOutputs(outputs map[string]string) error {
+	file := os.Getenv("GITHUB_OUTPUT")
+	if file == "" {
+		return nil
+	}
+
+	names := []string{}
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s=%s", name, outputs[name]))
+	}
+
+	return appendToFile(file, strings.Join(lines, "\n")+"\n")
+}
+
+//writeStepSummary writes the summary of the release, and of verifying signatures if enabled, to $GITHUB_STEP_SUMMARY
+func writeStepSummary(plugin index.Plugin, pr, signatures string) error {
+	file := os.Getenv("GITHUB_STEP_SUMMARY")
+	if file == "" {
+		return nil
+	}
+
+	lines := []string{
+		fmt.Sprintf("### Released %s %s to krew-index", plugin.GetName(), plugin.Spec.Version),
+		"",
+	}
+
+	if pr != "" {
+		lines = append(lines, fmt.Sprintf("PR: %s", pr), "")
+	}
+
+	lines = append(lines, "| Platform | URI | SHA256 |", "|----------|-----|--------|")
+	for _, platform := range plugin.Spec.Platforms {
+		name := ""
+		if platform.Selector != nil {
+			name = fmt.Sprintf("%s/%s", platform.Selector.MatchLabels["os"], platform.Selector.MatchLabels["arch"])
+		}
+
+		lines = append(lines, fmt.Sprintf("| %s | %s | `%s` |", name, platform.URI, platform.Sha256))
+	}
+
+	if signatures != "" {
+		lines = append(lines, "", "**Signature verification**", signatures)
+	}
+
+	return appendToFile(file, strings.Join(lines, "\n")+"\n")
+}
+
+func appendToFile(file, content string) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	return err
+}
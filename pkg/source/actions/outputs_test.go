@@ -0,0 +1,78 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestGetPRURL(t *testing.T) {
+	assert.Equal(t, "https://github.com/kubernetes-sigs/krew-index/pull/26", getPRURL(`PR "https://github.com/kubernetes-sigs/krew-index/pull/26" submitted successfully`))
+	assert.Equal(t, "https://github.com/kubernetes-sigs/krew-index/pull/26", getPRURL("https://github.com/kubernetes-sigs/krew-index/pull/26"))
+	assert.Equal(t, "", getPRURL("something went wrong"))
+}
+
+func TestWriteOutputsAndSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outputs-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Clearenv()
+	os.Setenv("GITHUB_OUTPUT", filepath.Join(dir, "output"))
+	os.Setenv("GITHUB_STEP_SUMMARY", filepath.Join(dir, "summary"))
+	manifestPath, err := saveManifest(dir, "whoami", []byte("kind: Plugin"))
+	assert.Nil(t, err)
+	assert.Equal(t, ".krew-release-bot/whoami.yaml", manifestPath)
+
+	manifest, err := ioutil.ReadFile(filepath.Join(dir, manifestPath))
+	assert.Nil(t, err)
+	assert.Equal(t, "kind: Plugin", string(manifest))
+
+	err = writeOutputs(map[string]string{
+		"pr-url":        "https://github.com/kubernetes-sigs/krew-index/pull/26",
+		"plugin-name":   "whoami",
+		"version":       "v0.0.2",
+		"manifest-path": manifestPath,
+	})
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(dir, "output"))
+	assert.Nil(t, err)
+	assert.Equal(t, "manifest-path="+manifestPath+"\nplugin-name=whoami\npr-url=https://github.com/kubernetes-sigs/krew-index/pull/26\nversion=v0.0.2\n", string(output))
+
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "whoami"},
+		Spec: index.PluginSpec{
+			Version: "v0.0.2",
+			Platforms: []index.Platform{
+				{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "linux", "arch": "amd64"}},
+					URI:      "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz",
+					Sha256:   "a6ffa097b132c8434379adc9620a6b728ad8434dbdaf38699650e19948265bdf",
+				},
+			},
+		},
+	}
+
+	err = writeStepSummary(plugin, "https://github.com/kubernetes-sigs/krew-index/pull/26", "- https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz: :white_check_mark: verified (verified with public key)")
+	assert.Nil(t, err)
+
+	summary, err := ioutil.ReadFile(filepath.Join(dir, "summary"))
+	assert.Nil(t, err)
+	assert.Equal(t, `### Released whoami v0.0.2 to krew-index
+
+PR: https://github.com/kubernetes-sigs/krew-index/pull/26
+
+| Platform | URI | SHA256 |
+|----------|-----|--------|
+| linux/amd64 | https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz | `+"`a6ffa097b132c8434379adc9620a6b728ad8434dbdaf38699650e19948265bdf`"+` |
+
+**Signature verification**
+- https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz: :white_check_mark: verified (verified with public key)
+`, string(summary))
+}
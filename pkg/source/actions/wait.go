@@ -0,0 +1,178 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/sirupsen/logrus"
+)
+
+//pollInterval is the interval at which the release is fetched again while waiting for it
+var pollInterval = 10 * time.Second
+
+var (
+	tagNamePlaceholder = regexp.MustCompile(`\{\{\s*\.TagName\s*\}\}`)
+	releaseAssetURL    = regexp.MustCompile(`https://github\.com/[^/\s"']+/[^/\s"']+/releases/download/[^\s"']+`)
+)
+
+//waitForRelease waits until the release for the tag is created and published, and the assets referenced
+//in the template are uploaded. The release can be nil, if it is not known yet.
+//With a zero timeout the release is checked only once
+func waitForRelease(client *github.Client, owner, repo, tag string, release *github.RepositoryRelease, assetNames []string, timeout time.Duration) (*github.RepositoryRelease, error) {
+	if release == nil {
+		var err error
+		release, err = fetchRelease(client, owner, repo, tag, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		reason := releaseNotReady(tag, release, assetNames)
+		if reason == "" {
+			return release, nil
+		}
+
+		if timeout == 0 {
+			return nil, fmt.Errorf("%s", reason)
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for release. %s", timeout, reason)
+		}
+
+		logrus.Infof("%s, checking again in %s", reason, pollInterval)
+		time.Sleep(pollInterval)
+
+		latest, err := fetchRelease(client, owner, repo, tag, release.GetID())
+		if err != nil {
+			return nil, err
+		}
+
+		release = latest
+	}
+}
+
+//fetchRelease fetches the release by id if it is known, otherwise by tag.
+//Drafts are not returned when fetching the release by tag, and are listed only for
+//clients authenticated with push access to the repo, so the releases are listed to find a draft.
+//A nil release is returned if no release is found for the tag yet
+func fetchRelease(client *github.Client, owner, repo, tag string, id int64) (*github.RepositoryRelease, error) {
+	if id != 0 {
+		release, _, err := client.Repositories.GetRelease(context.TODO(), owner, repo, id)
+		if err != nil {
+			return nil, err
+		}
+
+		return release, nil
+	}
+
+	release, resp, err := client.Repositories.GetReleaseByTag(context.TODO(), owner, repo, tag)
+	if err == nil {
+		return release, nil
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, err
+	}
+
+	releases, _, err := client.Repositories.ListReleases(context.TODO(), owner, repo, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range releases {
+		if r.GetTagName() == tag {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+//releaseNotReady returns why the release is not ready yet, or empty string if it is.
+//Only the assets in assetNames are checked, or all assets of the release when it is empty,
+//e.g. when the manifest is generated from the release assets
+func releaseNotReady(tag string, release *github.RepositoryRelease, assetNames []string) string {
+	if release == nil {
+		return fmt.Sprintf("release with tag %q not found", tag)
+	}
+
+	if release.GetDraft() {
+		return fmt.Sprintf("release with tag %q is a draft", release.GetTagName())
+	}
+
+	referenced := map[string]bool{}
+	for _, name := range assetNames {
+		referenced[name] = true
+	}
+
+	found := map[string]bool{}
+	for _, asset := range release.Assets {
+		if len(assetNames) > 0 && !referenced[asset.GetName()] {
+			continue
+		}
+
+		if asset.GetState() != "uploaded" {
+			return fmt.Sprintf("asset %s of release with tag %q is in state %q", asset.GetName(), release.GetTagName(), asset.GetState())
+		}
+
+		found[asset.GetName()] = true
+	}
+
+	for _, name := range assetNames {
+		if !found[name] {
+			return fmt.Sprintf("asset %s referenced in the template not found in release with tag %q", name, release.GetTagName())
+		}
+	}
+
+	return ""
+}
+
+//getReferencedAssets gets the names of release assets referenced in the template, e.g. in
+//addURIAndSha "https://github.com/foo/bar/releases/download/{{ .TagName }}/bar-linux-amd64.tar.gz" .TagName
+func getReferencedAssets(templateFile, tag string) ([]string, error) {
+	data, err := ioutil.ReadFile(templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	text := tagNamePlaceholder.ReplaceAllLiteralString(string(data), tag)
+
+	names := []string{}
+	for _, uri := range releaseAssetURL.FindAllString(text, -1) {
+		name := path.Base(uri)
+
+		//names rendered using other values cannot be known before rendering the template
+		if strings.Contains(name, "{{") || strings.Contains(name, "}}") {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+//getWaitTimeout gets the time to wait for the release assets to be uploaded
+func getWaitTimeout() (time.Duration, error) {
+	input := getInputForAction("wait_timeout")
+	if input == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(input)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wait_timeout %q. error: %v", input, err)
+	}
+
+	return timeout, nil
+}
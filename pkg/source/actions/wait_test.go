@@ -0,0 +1,231 @@
+package actions
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestGetReferencedAssets(t *testing.T) {
+	names, err := getReferencedAssets("data/.krew.yaml", "v0.0.2")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"darwin-amd64-v0.0.2.tar.gz", "linux-amd64-v0.0.2.tar.gz"}, names)
+}
+
+func TestWaitForRelease(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	defer func() {
+		pollInterval = 10 * time.Second
+	}()
+
+	testcases := []struct {
+		name          string
+		release       string
+		assetNames    []string
+		timeout       time.Duration
+		setupMocks    func()
+		expectedError string
+	}{
+		{
+			name:    "release is ready",
+			release: releaseWithAssets,
+		},
+		{
+			name:          "release is ready, but referenced asset is missing",
+			release:       releaseWithAssets,
+			assetNames:    []string{"windows-amd64-v0.0.2.zip"},
+			expectedError: `asset windows-amd64-v0.0.2.zip referenced in the template not found in release with tag "v0.0.2"`,
+		},
+		{
+			name:          "release is a draft, without timeout",
+			release:       draftRelease,
+			expectedError: `release with tag "v0.0.2" is a draft`,
+		},
+		{
+			name:          "release has no assets, without timeout",
+			release:       releaseNoAssets,
+			expectedError: `asset darwin-amd64-v0.0.2.tar.gz referenced in the template not found in release with tag "v0.0.2"`,
+		},
+		{
+			name:    "release is published and assets are uploaded while waiting",
+			release: draftRelease,
+			timeout: time.Second,
+			setupMocks: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/22569944").
+					MatchHeader("Authorization", "Bearer some-token").
+					Reply(200).
+					BodyString(releaseUploading)
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/22569944").
+					MatchHeader("Authorization", "Bearer some-token").
+					Reply(200).
+					BodyString(releaseWithAssets)
+			},
+		},
+		{
+			name:    "release is created as draft and published while waiting",
+			timeout: time.Second,
+			setupMocks: func() {
+				//release is not created yet
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Reply(404)
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases").
+					Reply(200).
+					BodyString(`[]`)
+
+				//release is created as draft
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Reply(404)
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases").
+					MatchHeader("Authorization", "Bearer some-token").
+					Reply(200).
+					BodyString("[" + draftRelease + "]")
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/22569944").
+					MatchHeader("Authorization", "Bearer some-token").
+					Reply(200).
+					BodyString(releaseWithAssets)
+			},
+		},
+		{
+			name: "release is not created yet, without timeout",
+			setupMocks: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Reply(404)
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases").
+					Reply(200).
+					BodyString(`[]`)
+			},
+			expectedError: `release with tag "v0.0.2" not found`,
+		},
+		{
+			name:    "release is not created before timeout",
+			timeout: 50 * time.Millisecond,
+			setupMocks: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Persist().
+					Reply(404)
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases").
+					Persist().
+					Reply(200).
+					BodyString(`[]`)
+			},
+			expectedError: `timed out after 50ms waiting for release. release with tag "v0.0.2" not found`,
+		},
+		{
+			name:    "assets are not uploaded before timeout",
+			release: releaseUploading,
+			timeout: 50 * time.Millisecond,
+			setupMocks: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/22569944").
+					Persist().
+					Reply(200).
+					BodyString(releaseUploading)
+			},
+			expectedError: `timed out after 50ms waiting for release. asset linux-amd64-v0.0.2.tar.gz of release with tag "v0.0.2" is in state "starter"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			if tc.setupMocks != nil {
+				tc.setupMocks()
+			}
+
+			//release is not known yet, unless it is in the event
+			var release *github.RepositoryRelease
+			if tc.release != "" {
+				release = &github.RepositoryRelease{}
+				err := json.Unmarshal([]byte(tc.release), release)
+				assert.Nil(t, err)
+			}
+
+			names := append([]string{"darwin-amd64-v0.0.2.tar.gz", "linux-amd64-v0.0.2.tar.gz"}, tc.assetNames...)
+			release, err := waitForRelease(newGithubClient("some-token"), "foo-bar", "my-awesome-plugin", "v0.0.2", release, names, tc.timeout)
+			assertError(t, tc.expectedError, err)
+			if tc.expectedError == "" {
+				assert.Equal(t, "v0.0.2", release.GetTagName())
+				assert.True(t, gock.IsDone())
+			}
+		})
+	}
+}
+
+func TestReleaseNotReady(t *testing.T) {
+	testcases := []struct {
+		name           string
+		assetNames     []string
+		expectedReason string
+	}{
+		{
+			name:       "asset not referenced in the template is still uploading",
+			assetNames: []string{"darwin-amd64-v0.0.2.tar.gz"},
+		},
+		{
+			name:           "asset referenced in the template is still uploading",
+			assetNames:     []string{"darwin-amd64-v0.0.2.tar.gz", "linux-amd64-v0.0.2.tar.gz"},
+			expectedReason: `asset linux-amd64-v0.0.2.tar.gz of release with tag "v0.0.2" is in state "starter"`,
+		},
+		{
+			name:           "no assets referenced, e.g. when generating the manifest",
+			expectedReason: `asset linux-amd64-v0.0.2.tar.gz of release with tag "v0.0.2" is in state "starter"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := &github.RepositoryRelease{}
+			err := json.Unmarshal([]byte(releaseUploading), release)
+			assert.Nil(t, err)
+
+			assert.Equal(t, tc.expectedReason, releaseNotReady("v0.0.2", release, tc.assetNames))
+		})
+	}
+}
+
+const draftRelease = `{
+	"id": 22569944,
+	"tag_name": "v0.0.2",
+	"name": "v0.0.2",
+	"draft": true,
+	"prerelease": false,
+	"assets": []
+}`
+
+const releaseUploading = `{
+	"id": 22569944,
+	"tag_name": "v0.0.2",
+	"name": "v0.0.2",
+	"prerelease": false,
+	"assets": [
+		{
+			"id": 16605457,
+			"name": "darwin-amd64-v0.0.2.tar.gz",
+			"state": "uploaded"
+		},
+		{
+			"id": 16605458,
+			"name": "linux-amd64-v0.0.2.tar.gz",
+			"state": "starter"
+		}
+	]
+}`
@@ -0,0 +1,143 @@
+package actionwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/pkg/errors"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/oidc"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+//GithubActions is github webhook handler
+type GithubActions struct {
+	//Verifier verifies the OIDC token of the workflow, if set. The plugin repo and tag
+	//are then taken from the claims in the token instead of the request body
+	Verifier *oidc.Verifier
+
+	client *github.Client
+}
+
+//NewGithubActions gets new git webhook instance. The token is used to check the tag
+//released by workflows which ran for a branch
+func NewGithubActions(token string) (*GithubActions, error) {
+	httpClient := http.DefaultClient
+	if token != "" {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		httpClient = oauth2.NewClient(context.TODO(), ts)
+	}
+
+	return &GithubActions{client: github.NewClient(httpClient)}, nil
+}
+
+//Parse validates the request
+func (w *GithubActions) Parse(r *http.Request) (*source.ReleaseRequest, error) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &source.ReleaseRequest{}
+	err = json.Unmarshal(body, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Verifier == nil {
+		return request, nil
+	}
+
+	err = w.applyTokenClaims(r, request)
+	if err != nil {
+		if _, ok := err.(*releaser.ReleaseError); !ok {
+			err = &releaser.ReleaseError{Code: source.ErrorCodeUnauthorized, Err: err}
+		}
+
+		return nil, err
+	}
+
+	return request, nil
+}
+
+//applyTokenClaims verifies the OIDC token in the authorization header, and sets the plugin repo,
+//tag and actor of the request from the claims, so that they cannot be faked in the request body.
+//Workflows which ran for a branch e.g. on workflow_dispatch with the tag input, can release the tag
+//in the request body only if it points to the commit the workflow ran for
+func (w *GithubActions) applyTokenClaims(r *http.Request, request *source.ReleaseRequest) error {
+	authorization := r.Header.Get("authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return fmt.Errorf("oidc token not found in authorization header. add 'id-token: write' to permissions of the workflow")
+	}
+
+	claims, err := w.Verifier.Verify(strings.TrimPrefix(authorization, "Bearer "))
+	if err != nil {
+		return errors.Wrap(err, "verifying oidc token")
+	}
+
+	owner, repo, err := claims.OwnerAndRepo()
+	if err != nil {
+		return err
+	}
+
+	tag, err := w.tagForClaims(claims, owner, repo, request.TagName)
+	if err != nil {
+		return err
+	}
+
+	if request.PluginOwner != owner || request.PluginRepo != repo || request.TagName != tag {
+		logrus.Warnf("release request for %s/%s tag %q does not match oidc token for %s/%s tag %q, using the token", request.PluginOwner, request.PluginRepo, request.TagName, owner, repo, tag)
+	}
+
+	request.PluginOwner = owner
+	request.PluginRepo = repo
+	request.TagName = tag
+	if claims.Actor != "" {
+		request.PluginReleaseActor = claims.Actor
+	}
+
+	return nil
+}
+
+//tagForClaims gets the tag to release. It is the tag in the ref claim, or for a branch,
+//the tag in the request body if it points to the commit in the sha claim
+func (w *GithubActions) tagForClaims(claims *oidc.Claims, owner, repo, requestTag string) (string, error) {
+	if tag, ok := claims.Tag(); ok {
+		return tag, nil
+	}
+
+	if _, ok := claims.Branch(); !ok {
+		return "", fmt.Errorf("ref %q in oidc token is not a tag or a branch", claims.Ref)
+	}
+
+	if requestTag == "" {
+		return "", fmt.Errorf("ref %q in oidc token is not a tag, and tag not found in the release request", claims.Ref)
+	}
+
+	if claims.SHA == "" {
+		return "", fmt.Errorf("sha claim not found in oidc token for ref %q", claims.Ref)
+	}
+
+	sha, resp, err := w.client.Repositories.GetCommitSHA1(context.TODO(), owner, repo, requestTag, "")
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", &releaser.ReleaseError{Code: source.ErrorCodeTagNotAtCommit, Err: fmt.Errorf("tag %q not found in %s/%s", requestTag, owner, repo)}
+		}
+
+		return "", &releaser.ReleaseError{Code: source.ErrorCodeUpstreamError, Err: errors.Wrapf(err, "fetching commit of tag %q", requestTag)}
+	}
+
+	if sha != claims.SHA {
+		return "", &releaser.ReleaseError{Code: source.ErrorCodeTagNotAtCommit, Err: fmt.Errorf("tag %q points to commit %s, but the workflow ran for %s at commit %s", requestTag, sha, claims.Ref, claims.SHA)}
+	}
+
+	return requestTag, nil
+}
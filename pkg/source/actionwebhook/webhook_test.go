@@ -0,0 +1,207 @@
+package actionwebhook
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/oidc"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.Nil(t, err)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func newTestVerifier(t *testing.T) (*oidc.Verifier, func(claims map[string]interface{}) string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+
+	data, err := json.Marshal(jwks)
+	assert.Nil(t, err)
+
+	keys, err := oidc.NewStaticKeySet(data)
+	assert.Nil(t, err)
+
+	sign := func(claims map[string]interface{}) string {
+		signingInput := encodeSegment(t, map[string]string{"alg": "RS256", "kid": "key-1"}) + "." + encodeSegment(t, claims)
+		digest := sha256.Sum256([]byte(signingInput))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		assert.Nil(t, err)
+
+		return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+
+	return oidc.NewVerifier("krew-release-bot", keys), sign
+}
+
+func TestParseWithOIDCToken(t *testing.T) {
+	verifier, sign := newTestVerifier(t)
+
+	claims := func(ref string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":        oidc.GithubIssuer,
+			"aud":        "krew-release-bot",
+			"exp":        time.Now().Add(time.Hour).Unix(),
+			"repository": "foo-bar/my-awesome-plugin",
+			"ref":        ref,
+			"sha":        "8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e",
+			"actor":      "rajatjindal",
+		}
+	}
+
+	//the body claims to release another plugin
+	evilBody := `{"tagName": "v9.9.9", "pluginOwner": "evil", "pluginRepo": "kubectl-whoami", "pluginReleaseActor": "evil"}`
+
+	//the body of a workflow_dispatch run with the tag input
+	dispatchBody := `{"tagName": "v0.0.2", "pluginOwner": "foo-bar", "pluginRepo": "my-awesome-plugin", "pluginReleaseActor": "rajatjindal"}`
+
+	testcases := []struct {
+		name            string
+		body            string
+		authorization   string
+		setupMocks      func()
+		expectedRequest *source.ReleaseRequest
+		expectedCode    string
+		expectedError   string
+	}{
+		{
+			name:          "repo and tag are taken from the token",
+			authorization: "Bearer " + sign(claims("refs/tags/v0.0.2")),
+			expectedRequest: &source.ReleaseRequest{
+				TagName:            "v0.0.2",
+				PluginOwner:        "foo-bar",
+				PluginRepo:         "my-awesome-plugin",
+				PluginReleaseActor: "rajatjindal",
+			},
+		},
+		{
+			name:          "tag of the request at the commit of the branch",
+			body:          dispatchBody,
+			authorization: "Bearer " + sign(claims("refs/heads/master")),
+			setupMocks: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v0.0.2").
+					Reply(200).
+					BodyString("8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e")
+			},
+			expectedRequest: &source.ReleaseRequest{
+				TagName:            "v0.0.2",
+				PluginOwner:        "foo-bar",
+				PluginRepo:         "my-awesome-plugin",
+				PluginReleaseActor: "rajatjindal",
+			},
+		},
+		{
+			name:          "tag of the request at another commit than the branch",
+			authorization: "Bearer " + sign(claims("refs/heads/master")),
+			setupMocks: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v9.9.9").
+					Reply(200).
+					BodyString("0123456789abcdef0123456789abcdef01234567")
+			},
+			expectedCode:  source.ErrorCodeTagNotAtCommit,
+			expectedError: `tag "v9.9.9" points to commit 0123456789abcdef0123456789abcdef01234567, but the workflow ran for refs/heads/master at commit 8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e`,
+		},
+		{
+			name:          "tag of the request not found",
+			authorization: "Bearer " + sign(claims("refs/heads/master")),
+			setupMocks: func() {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v9.9.9").
+					Reply(404).
+					JSON(`{"message": "Not Found"}`)
+			},
+			expectedCode:  source.ErrorCodeTagNotAtCommit,
+			expectedError: `tag "v9.9.9" not found in foo-bar/my-awesome-plugin`,
+		},
+		{
+			name:          "tag not found in the request for a branch",
+			body:          `{"pluginOwner": "foo-bar", "pluginRepo": "my-awesome-plugin"}`,
+			authorization: "Bearer " + sign(claims("refs/heads/master")),
+			expectedError: `ref "refs/heads/master" in oidc token is not a tag, and tag not found in the release request`,
+		},
+		{
+			name:          "token missing",
+			expectedError: "oidc token not found in authorization header. add 'id-token: write' to permissions of the workflow",
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer foo",
+			expectedError: "verifying oidc token: token is not a valid jwt",
+		},
+		{
+			name:          "ref is a pull request",
+			authorization: "Bearer " + sign(claims("refs/pull/1/merge")),
+			expectedError: `ref "refs/pull/1/merge" in oidc token is not a tag or a branch`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			if tc.setupMocks != nil {
+				tc.setupMocks()
+			}
+
+			body := evilBody
+			if tc.body != "" {
+				body = tc.body
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/github-action-webhook", bytes.NewBufferString(body))
+			if tc.authorization != "" {
+				r.Header.Set("authorization", tc.authorization)
+			}
+
+			hook := &GithubActions{Verifier: verifier, client: github.NewClient(nil)}
+			request, err := hook.Parse(r)
+			assert.True(t, gock.IsDone())
+			if tc.expectedError != "" {
+				expectedCode := tc.expectedCode
+				if expectedCode == "" {
+					expectedCode = source.ErrorCodeUnauthorized
+				}
+
+				assert.EqualError(t, err, tc.expectedError)
+				releaseErr, ok := err.(*releaser.ReleaseError)
+				assert.True(t, ok)
+				if ok {
+					assert.Equal(t, expectedCode, releaseErr.Code)
+				}
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedRequest, request)
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package cli
+
+import (
+	"github.com/rajatjindal/krew-release-bot/pkg/source/actions"
+)
+
+//Run releases the plugin using the same pipeline as the github action
+func Run(config *Config) error {
+	actionConfig, err := config.actionConfig()
+	if err != nil {
+		return err
+	}
+
+	return actions.RunAction(actionConfig)
+}
@@ -0,0 +1,289 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/actions"
+	"sigs.k8s.io/yaml"
+)
+
+//Config is the configuration of the release, when publishing from CI systems other than github actions
+//or from a local machine. It provides the same information as the inputs and env of the github action
+type Config struct {
+	//Tag is the tag of the release e.g. v1.2.3
+	Tag string `json:"tag"`
+
+	//Repo is the plugin repo as <owner>/<repo>
+	Repo string `json:"repo"`
+
+	//Actor is the user who released the plugin. defaults to the owner of the repo
+	Actor string `json:"actor"`
+
+	Workdir              string   `json:"workdir"`
+	TemplateFile         string   `json:"templateFile"`
+	PluginName           string   `json:"pluginName"`
+	TemplateEnvAllowlist []string `json:"templateEnvAllowlist"`
+	Strict               bool     `json:"strict"`
+	TagPrefix            string   `json:"tagPrefix"`
+	TagPattern           string   `json:"tagPattern"`
+	WaitTimeout          string   `json:"waitTimeout"`
+
+	//WebhookURL is the url of krew-release-bot webhook the release is submitted to
+	WebhookURL string `json:"webhookURL"`
+
+	//Token is used to read the release, including drafts, from github api
+	Token string `json:"token"`
+
+	//Standalone opens the PR in krew-index directly, using GithubToken and KrewIndexFork
+	Standalone    bool   `json:"standalone"`
+	GithubToken   string `json:"githubToken"`
+	KrewIndexFork string `json:"krewIndexFork"`
+
+	//files with the PEM encoded key and certificates to verify the signatures with
+	CosignPublicKeyFile         string `json:"cosignPublicKeyFile"`
+	CosignCertificateIdentity   string `json:"cosignCertificateIdentity"`
+	CosignCertificateOIDCIssuer string `json:"cosignCertificateOIDCIssuer"`
+	CosignCertificateRootsFile  string `json:"cosignCertificateRootsFile"`
+	RekorPublicKeyFile          string `json:"rekorPublicKeyFile"`
+	RequireSignatures           bool   `json:"requireSignatures"`
+
+	//PolicyFile is the policy applied to the plugin manifest when running standalone,
+	//in the format of the policy file of the krew-release-bot server
+	PolicyFile string `json:"policyFile"`
+}
+
+//LoadConfig loads the config from yaml file
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	err = yaml.UnmarshalStrict(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s. error: %v", file, err)
+	}
+
+	return config, nil
+}
+
+//ParseArgs parses the config from the command line. Flags override the values in the config file,
+//if one is provided using -config. The tokens default to env GITHUB_TOKEN
+func ParseArgs(args []string) (*Config, error) {
+	configFile := ""
+	fs := newFlagSet(&Config{}, &configFile)
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if configFile != "" {
+		config, err = LoadConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//parse again into the config loaded from file, so that only flags which are set override it
+	err = newFlagSet(config, &configFile).Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Token == "" {
+		config.Token = os.Getenv("GITHUB_TOKEN")
+	}
+
+	if config.GithubToken == "" {
+		config.GithubToken = os.Getenv("GITHUB_TOKEN")
+	}
+
+	return config, config.Validate()
+}
+
+func newFlagSet(config *Config, configFile *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("krew-release-bot", flag.ContinueOnError)
+	fs.StringVar(configFile, "config", *configFile, "yaml file with the config. flags override the values in it")
+	fs.StringVar(&config.Tag, "tag", config.Tag, "tag of the release e.g. v1.2.3")
+	fs.StringVar(&config.Repo, "repo", config.Repo, "plugin repo as <owner>/<repo>")
+	fs.StringVar(&config.Actor, "actor", config.Actor, "user who released the plugin. defaults to the owner of the repo")
+	fs.StringVar(&config.Workdir, "workdir", config.Workdir, "working directory. defaults to the current directory")
+	fs.StringVar(&config.TemplateFile, "template-file", config.TemplateFile, "path to template file relative to workdir. defaults to .krew.yaml")
+	fs.StringVar(&config.PluginName, "plugin-name", config.PluginName, "name of the plugin in krew-index, used when generating the manifest without a template")
+	fs.Var((*stringList)(&config.TemplateEnvAllowlist), "template-env-allowlist", "comma separated list of env variables that can be read in the template")
+	fs.BoolVar(&config.Strict, "strict", config.Strict, "fail on unrendered placeholders and unknown fields in the rendered template")
+	fs.StringVar(&config.TagPrefix, "tag-prefix", config.TagPrefix, "prefix of the tag before the version e.g. release-")
+	fs.StringVar(&config.TagPattern, "tag-pattern", config.TagPattern, "regular expression with named captures plugin and version, for repos with multiple plugins")
+	fs.StringVar(&config.WaitTimeout, "wait-timeout", config.WaitTimeout, "how long to wait for the release assets to be uploaded e.g. 10m")
+	fs.StringVar(&config.WebhookURL, "webhook-url", config.WebhookURL, "url of krew-release-bot webhook")
+	fs.StringVar(&config.Token, "token", config.Token, "token used to read the release, including drafts, from github api. defaults to env GITHUB_TOKEN")
+	fs.BoolVar(&config.Standalone, "standalone", config.Standalone, "open the PR in krew-index directly, instead of through krew-release-bot")
+	fs.StringVar(&config.GithubToken, "github-token", config.GithubToken, "token used to push to the krew-index fork and open the PR when standalone. defaults to env GITHUB_TOKEN")
+	fs.StringVar(&config.KrewIndexFork, "krew-index-fork", config.KrewIndexFork, "fork of krew-index as <owner>/<repo> when standalone. defaults to <token user>/krew-index")
+	fs.StringVar(&config.CosignPublicKeyFile, "cosign-public-key", config.CosignPublicKeyFile, "file with the PEM encoded public key to verify cosign signatures with")
+	fs.StringVar(&config.CosignCertificateIdentity, "cosign-certificate-identity", config.CosignCertificateIdentity, "expected email or uri in the signing certificate")
+	fs.StringVar(&config.CosignCertificateOIDCIssuer, "cosign-certificate-oidc-issuer", config.CosignCertificateOIDCIssuer, "expected oidc issuer of the signing certificate")
+	fs.StringVar(&config.CosignCertificateRootsFile, "cosign-certificate-roots", config.CosignCertificateRootsFile, "file with the PEM encoded CA certificates the signing certificate chains up to")
+	fs.StringVar(&config.RekorPublicKeyFile, "rekor-public-key", config.RekorPublicKeyFile, "file with the PEM encoded public key of the rekor transparency log keyless signatures are logged in")
+	fs.BoolVar(&config.RequireSignatures, "require-signatures", config.RequireSignatures, "fail when a release archive is not signed")
+	fs.StringVar(&config.PolicyFile, "policy", config.PolicyFile, "file with the policy applied to the plugin manifest when standalone, in the format of the policy file of krew-release-bot server")
+	return fs
+}
+
+//Validate checks that the required values are set
+func (c *Config) Validate() error {
+	if c.Tag == "" {
+		return fmt.Errorf("tag is required")
+	}
+
+	s := strings.Split(c.Repo, "/")
+	if len(s) != 2 || s[0] == "" || s[1] == "" {
+		return fmt.Errorf("repo is incorrect format. expected format <owner>/<repo>, found %q", c.Repo)
+	}
+
+	if c.WaitTimeout != "" {
+		if _, err := time.ParseDuration(c.WaitTimeout); err != nil {
+			return fmt.Errorf("invalid wait timeout %q. error: %v", c.WaitTimeout, err)
+		}
+	}
+
+	if c.Standalone && c.GithubToken == "" {
+		return fmt.Errorf("github token is required when running standalone")
+	}
+
+	return nil
+}
+
+//actionConfig returns the config of the release pipeline shared with the github action
+func (c *Config) actionConfig() (*actions.Config, error) {
+	workdir := c.Workdir
+	if workdir == "" {
+		workdir = "."
+	}
+
+	workdir, err := filepath.Abs(workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	waitTimeout, err := parseDuration(c.WaitTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s := strings.Split(c.Repo, "/")
+	actor := c.Actor
+	if actor == "" {
+		actor = s[0]
+	}
+
+	signatures, err := c.signatureVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	var policy *releaser.Policy
+	if c.PolicyFile != "" {
+		policy, err = releaser.LoadPolicy(c.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &actions.Config{
+		Tag:                  c.Tag,
+		Owner:                s[0],
+		Repo:                 s[1],
+		Actor:                actor,
+		Workspace:            workdir,
+		Workdir:              workdir,
+		TemplateFile:         c.TemplateFile,
+		PluginName:           c.PluginName,
+		TemplateEnvAllowlist: c.TemplateEnvAllowlist,
+		Strict:               c.Strict,
+		TagPrefix:            c.TagPrefix,
+		TagPattern:           c.TagPattern,
+		WaitTimeout:          waitTimeout,
+		Token:                c.Token,
+		WebhookURL:           c.WebhookURL,
+		Standalone:           c.Standalone,
+		GithubToken:          c.GithubToken,
+		KrewIndexFork:        c.KrewIndexFork,
+		Signatures:           signatures,
+		Policy:               policy,
+	}, nil
+}
+
+//signatureVerifier returns the verifier for cosign signatures, reading the key and certificates
+//from files. It returns nil if signature verification is not configured
+func (c *Config) signatureVerifier() (*source.SignatureVerifier, error) {
+	if c.CosignPublicKeyFile == "" && c.CosignCertificateIdentity == "" && !c.RequireSignatures {
+		return nil, nil
+	}
+
+	verifier := &source.SignatureVerifier{
+		CertificateIdentity:   c.CosignCertificateIdentity,
+		CertificateOIDCIssuer: c.CosignCertificateOIDCIssuer,
+		Required:              c.RequireSignatures,
+	}
+
+	var err error
+	if c.CosignPublicKeyFile != "" {
+		verifier.PublicKey, err = ioutil.ReadFile(c.CosignPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.CosignCertificateRootsFile != "" {
+		verifier.CertificateRoots, err = ioutil.ReadFile(c.CosignCertificateRootsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.RekorPublicKeyFile != "" {
+		verifier.RekorPublicKey, err = ioutil.ReadFile(c.RekorPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return verifier, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(s)
+}
+
+//stringList is a flag with comma separated values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+
+	return nil
+}
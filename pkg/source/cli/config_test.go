@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgs(t *testing.T) {
+	testcases := []struct {
+		name           string
+		args           []string
+		env            map[string]string
+		expectedConfig *Config
+		expectedError  string
+	}{
+		{
+			name: "flags",
+			args: []string{"-tag", "v0.0.2", "-repo", "foo-bar/my-awesome-plugin", "-template-env-allowlist", "FOO, BAR", "-strict"},
+			expectedConfig: &Config{
+				Tag:                  "v0.0.2",
+				Repo:                 "foo-bar/my-awesome-plugin",
+				TemplateEnvAllowlist: []string{"FOO", "BAR"},
+				Strict:               true,
+			},
+		},
+		{
+			name: "flags override config file",
+			args: []string{"-config", "data/config.yaml", "-tag", "v0.0.2", "-strict=false"},
+			expectedConfig: &Config{
+				Tag:                  "v0.0.2",
+				Repo:                 "foo-bar/my-awesome-plugin",
+				TemplateFile:         ".krew/my-awesome-plugin.yaml",
+				TemplateEnvAllowlist: []string{"PLUGIN_CAVEATS"},
+				WaitTimeout:          "10m",
+			},
+		},
+		{
+			name: "github token from env when standalone",
+			args: []string{"-tag", "v0.0.2", "-repo", "foo-bar/my-awesome-plugin", "-standalone"},
+			env:  map[string]string{"GITHUB_TOKEN": "some-token"},
+			expectedConfig: &Config{
+				Tag:         "v0.0.2",
+				Repo:        "foo-bar/my-awesome-plugin",
+				Token:       "some-token",
+				Standalone:  true,
+				GithubToken: "some-token",
+			},
+		},
+		{
+			name:          "tag is required",
+			args:          []string{"-repo", "foo-bar/my-awesome-plugin"},
+			expectedError: "tag is required",
+		},
+		{
+			name:          "repo is incorrect format",
+			args:          []string{"-tag", "v0.0.2", "-repo", "my-awesome-plugin"},
+			expectedError: `repo is incorrect format. expected format <owner>/<repo>, found "my-awesome-plugin"`,
+		},
+		{
+			name:          "github token is required when standalone",
+			args:          []string{"-tag", "v0.0.2", "-repo", "foo-bar/my-awesome-plugin", "-standalone"},
+			expectedError: "github token is required when running standalone",
+		},
+		{
+			name:          "invalid wait timeout",
+			args:          []string{"-tag", "v0.0.2", "-repo", "foo-bar/my-awesome-plugin", "-wait-timeout", "10"},
+			expectedError: `invalid wait timeout "10". error: time: missing unit in duration "10"`,
+		},
+		{
+			name:          "unknown field in config file",
+			args:          []string{"-config", "data/unknown-field.yaml"},
+			expectedError: `parsing config file data/unknown-field.yaml. error: error unmarshaling JSON: while decoding JSON: json: unknown field "repository"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("GITHUB_TOKEN", tc.env["GITHUB_TOKEN"])
+			defer os.Unsetenv("GITHUB_TOKEN")
+
+			config, err := ParseArgs(tc.args)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedConfig, config)
+		})
+	}
+}
+
+func TestActionConfig(t *testing.T) {
+	config := &Config{
+		Tag:                  "v0.0.2",
+		Repo:                 "foo-bar/my-awesome-plugin",
+		Workdir:              "data",
+		TemplateEnvAllowlist: []string{"FOO", "BAR"},
+		WaitTimeout:          "10m",
+		Token:                "read-token",
+		Standalone:           true,
+		GithubToken:          "some-token",
+		CosignPublicKeyFile:  "data/config.yaml",
+		RekorPublicKeyFile:   "data/config.yaml",
+		PolicyFile:           "data/policy.yaml",
+	}
+
+	actionConfig, err := config.actionConfig()
+	assert.Nil(t, err)
+
+	workdir, _ := filepath.Abs("data")
+	assert.Equal(t, "foo-bar", actionConfig.Owner)
+	assert.Equal(t, "my-awesome-plugin", actionConfig.Repo)
+	assert.Equal(t, "foo-bar", actionConfig.Actor)
+	assert.Equal(t, workdir, actionConfig.Workspace)
+	assert.Equal(t, workdir, actionConfig.Workdir)
+	assert.Equal(t, "v0.0.2", actionConfig.Tag)
+	assert.Equal(t, []string{"FOO", "BAR"}, actionConfig.TemplateEnvAllowlist)
+	assert.Equal(t, 10*time.Minute, actionConfig.WaitTimeout)
+	assert.Equal(t, "read-token", actionConfig.Token)
+	assert.True(t, actionConfig.Standalone)
+	assert.Equal(t, "some-token", actionConfig.GithubToken)
+	assert.Contains(t, string(actionConfig.Signatures.PublicKey), "tag: v0.0.1")
+	assert.Empty(t, actionConfig.Signatures.CertificateRoots)
+	assert.Contains(t, string(actionConfig.Signatures.RekorPublicKey), "tag: v0.0.1")
+	assert.Equal(t, []string{"downloads.example.com"}, actionConfig.Policy.Plugins["whoami"].AllowedHosts)
+
+	//workflow commands are written only when running as github action
+	assert.False(t, actionConfig.GithubActions)
+}
+
+func TestActionConfigWithoutSignatures(t *testing.T) {
+	config := &Config{Tag: "v0.0.2", Repo: "foo-bar/my-awesome-plugin", Actor: "release-actor"}
+
+	actionConfig, err := config.actionConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "release-actor", actionConfig.Actor)
+	assert.Nil(t, actionConfig.Signatures)
+	assert.Nil(t, actionConfig.Policy)
+}
@@ -0,0 +1,130 @@
+package source
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//maxReadFileSize is the max size of a file that can be read using readFile in the template
+const maxReadFileSize = 1 << 20
+
+//helperFuncs returns the helper functions available in the .krew.yaml template.
+//The argument order follows sprig, so that these can be used in pipelines
+//e.g. {{ .TagName | trimPrefix "v" }}
+func helperFuncs(opts TemplateOptions) template.FuncMap {
+	return template.FuncMap{
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"trim":       strings.TrimSpace,
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"title":      strings.Title,
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"quote":      strconv.Quote,
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"default":    defaultValue,
+		"env": func(name string) (string, error) {
+			return lookupEnv(opts.EnvAllowlist, name)
+		},
+		"readFile": func(file string) (string, error) {
+			return readFile(opts.Workdir, file)
+		},
+	}
+}
+
+//indent indents all non-empty lines of text, and drops the trailing newline,
+//so that it can be used in a yaml block scalar
+func indent(spaces int, text string) string {
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func defaultValue(d, given string) string {
+	if given == "" {
+		return d
+	}
+
+	return given
+}
+
+//lookupEnv returns the value of env variable, only if it is in the allowlist.
+//This prevents templates from leaking secrets available in the environment
+func lookupEnv(allowlist []string, name string) (string, error) {
+	for _, allowed := range allowlist {
+		if allowed == name {
+			return os.Getenv(name), nil
+		}
+	}
+
+	return "", fmt.Errorf("env %q is not in the allowlist of env variables available to the template", name)
+}
+
+//readFile reads a file relative to the workdir. Files outside of workdir cannot be read
+func readFile(workdir, file string) (string, error) {
+	if workdir == "" {
+		return "", fmt.Errorf("workdir not set, cannot read file %q", file)
+	}
+
+	if filepath.IsAbs(file) {
+		return "", fmt.Errorf("file %q must be relative to the workdir", file)
+	}
+
+	root, err := filepath.EvalSymlinks(workdir)
+	if err != nil {
+		return "", err
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := filepath.EvalSymlinks(filepath.Join(root, file))
+	if err != nil {
+		return "", err
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %q is outside of the workdir", file)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("file %q is not a regular file", file)
+	}
+
+	if info.Size() > maxReadFileSize {
+		return "", fmt.Errorf("file %q is bigger than %d bytes", file, maxReadFileSize)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
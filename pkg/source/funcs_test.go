@@ -0,0 +1,178 @@
+package source
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelperFuncs(t *testing.T) {
+	testcases := []struct {
+		name          string
+		template      string
+		setup         func()
+		opts          TemplateOptions
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "trimPrefix",
+			template: `{{ "v1.2.3" | trimPrefix "v" }}`,
+			expected: "1.2.3",
+		},
+		{
+			name:     "trimSuffix",
+			template: `{{ "kubectl-whoami.exe" | trimSuffix ".exe" }}`,
+			expected: "kubectl-whoami",
+		},
+		{
+			name:     "trim",
+			template: `{{ trim "  whoami  " }}`,
+			expected: "whoami",
+		},
+		{
+			name:     "lower",
+			template: `{{ lower "Darwin" }}`,
+			expected: "darwin",
+		},
+		{
+			name:     "upper",
+			template: `{{ upper "whoami" }}`,
+			expected: "WHOAMI",
+		},
+		{
+			name:     "title",
+			template: `{{ title "linux" }}`,
+			expected: "Linux",
+		},
+		{
+			name:     "replace",
+			template: `{{ "v1.2.3" | replace "." "-" }}`,
+			expected: "v1-2-3",
+		},
+		{
+			name:     "contains",
+			template: `{{ if contains "rc" "v1.2.3-rc.1" }}prerelease{{ end }}`,
+			expected: "prerelease",
+		},
+		{
+			name:     "hasPrefix",
+			template: `{{ if hasPrefix "v" "v1.2.3" }}prefixed{{ end }}`,
+			expected: "prefixed",
+		},
+		{
+			name:     "quote",
+			template: `{{ quote "it's \"quoted\"" }}`,
+			expected: `"it's \"quoted\""`,
+		},
+		{
+			name:     "indent",
+			template: "{{ indent 4 \"line 1\\n\\nline 2\\n\" }}",
+			expected: "    line 1\n\n    line 2",
+		},
+		{
+			name:     "nindent",
+			template: "caveats: |{{ nindent 4 \"line 1\\nline 2\" }}",
+			expected: "caveats: |\n    line 1\n    line 2",
+		},
+		{
+			name:     "default when value is empty",
+			template: `{{ "" | default "none" }}`,
+			expected: "none",
+		},
+		{
+			name:     "default when value is set",
+			template: `{{ "some" | default "none" }}`,
+			expected: "some",
+		},
+		{
+			name:     "env in allowlist",
+			template: `{{ env "PLUGIN_CAVEATS" }}`,
+			setup: func() {
+				os.Setenv("PLUGIN_CAVEATS", "requires kubectl v1.15+")
+			},
+			opts:     TemplateOptions{EnvAllowlist: []string{"PLUGIN_CAVEATS"}},
+			expected: "requires kubectl v1.15+",
+		},
+		{
+			name:     "env not in allowlist",
+			template: `{{ env "GITHUB_TOKEN" }}`,
+			setup: func() {
+				os.Setenv("GITHUB_TOKEN", "secret")
+			},
+			opts:          TemplateOptions{EnvAllowlist: []string{"PLUGIN_CAVEATS"}},
+			expectedError: `template: test:1:3: executing "test" at <env "GITHUB_TOKEN">: error calling env: env "GITHUB_TOKEN" is not in the allowlist of env variables available to the template`,
+		},
+		{
+			name:     "readFile in workdir",
+			template: `{{ readFile "CAVEATS.md" | indent 2 }}`,
+			opts:     TemplateOptions{Workdir: "data/readfile"},
+			expected: "  Requires kubectl v1.15+\n  and a kubeconfig",
+		},
+		{
+			name:          "readFile outside workdir",
+			template:      `{{ readFile "../context.krew.yaml" }}`,
+			opts:          TemplateOptions{Workdir: "data/readfile"},
+			expectedError: `template: test:1:3: executing "test" at <readFile "../context.krew.yaml">: error calling readFile: file "../context.krew.yaml" is outside of the workdir`,
+		},
+		{
+			name:          "readFile with absolute path",
+			template:      `{{ readFile "/etc/passwd" }}`,
+			opts:          TemplateOptions{Workdir: "data/readfile"},
+			expectedError: `template: test:1:3: executing "test" at <readFile "/etc/passwd">: error calling readFile: file "/etc/passwd" must be relative to the workdir`,
+		},
+		{
+			name:          "readFile without workdir",
+			template:      `{{ readFile "CAVEATS.md" }}`,
+			expectedError: `template: test:1:3: executing "test" at <readFile "CAVEATS.md">: error calling readFile: workdir not set, cannot read file "CAVEATS.md"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+			if tc.setup != nil {
+				tc.setup()
+			}
+
+			actual, err := executeHelperTemplate(tc.template, tc.opts)
+			assert.Equal(t, tc.expected, actual)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestReadFileSymlinkOutsideWorkdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workdir-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	outside, err := filepath.Abs("data/context.krew.yaml")
+	assert.Nil(t, err)
+
+	err = os.Symlink(outside, filepath.Join(dir, "CAVEATS.md"))
+	assert.Nil(t, err)
+
+	_, err = readFile(dir, "CAVEATS.md")
+	assertError(t, `file "CAVEATS.md" is outside of the workdir`, err)
+}
+
+func executeHelperTemplate(text string, opts TemplateOptions) (string, error) {
+	t, err := template.New("test").Funcs(helperFuncs(opts)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
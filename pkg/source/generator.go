@@ -0,0 +1,242 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+//ManifestInfo is the information used to generate a krew manifest when no template is available
+type ManifestInfo struct {
+	PluginName       string
+	Version          string
+	Homepage         string
+	ShortDescription string
+	Description      string
+	Caveats          string
+
+	//Existing is the plugin manifest currently in krew-index, if any.
+	//bin and files of its platforms are reused for matching os/arch
+	Existing *index.Plugin
+
+	//Assets maps the release asset names to their download urls
+	Assets map[string]string
+
+	//Signatures verifies the signatures of the archives, if set
+	Signatures *SignatureVerifier
+}
+
+type generatedPlatform struct {
+	OS     string
+	Arch   string
+	URI    string
+	Sha256 string
+	Files  []index.FileOperation
+	Bin    string
+}
+
+var archiveExtensions = []string{".tar.gz", ".tgz", ".zip"}
+
+//tokens found in asset names, mapped to the values krew uses for os and arch selectors
+var (
+	osTokens = map[string]string{
+		"darwin":  "darwin",
+		"macos":   "darwin",
+		"osx":     "darwin",
+		"linux":   "linux",
+		"windows": "windows",
+	}
+
+	archTokens = map[string]string{
+		"amd64":   "amd64",
+		"x86_64":  "amd64",
+		"x64":     "amd64",
+		"386":     "386",
+		"i386":    "386",
+		"x86":     "386",
+		"arm64":   "arm64",
+		"aarch64": "arm64",
+		"arm":     "arm",
+		"armv6":   "arm",
+		"armv7":   "arm",
+	}
+)
+
+const generatedManifestTemplate = `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: {{ quote .Info.PluginName }}
+spec:
+  version: {{ quote .Info.Version }}
+  homepage: {{ quote .Info.Homepage }}
+  shortDescription: {{ quote .Info.ShortDescription }}
+{{- if .Info.Description }}
+  description: |
+{{ indent 4 .Info.Description }}
+{{- end }}
+{{- if .Info.Caveats }}
+  caveats: |
+{{ indent 4 .Info.Caveats }}
+{{- end }}
+  platforms:
+{{- range .Platforms }}
+  - selector:
+      matchLabels:
+        os: {{ .OS }}
+        arch: {{ .Arch }}
+    uri: {{ quote .URI }}
+    sha256: {{ .Sha256 }}
+{{- if .Files }}
+    files:
+{{- range .Files }}
+    - from: {{ quote .From }}
+      to: {{ quote .To }}
+{{- end }}
+{{- end }}
+    bin: {{ quote .Bin }}
+{{- end }}
+`
+
+//GenerateManifest generates a krew manifest from the release assets that match
+//the <name>[-_]<os>[-_]<arch>.<tar.gz|tgz|zip> naming convention
+func GenerateManifest(info ManifestInfo) ([]byte, error) {
+	names := []string{}
+	for name := range info.Assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seen := map[string]bool{}
+	platforms := []generatedPlatform{}
+	for _, name := range names {
+		goos, goarch, ok := PlatformForAsset(name)
+		if !ok {
+			logrus.Infof("skipping asset %s as it does not match any platform", name)
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", goos, goarch)
+		if seen[key] {
+			logrus.Infof("skipping asset %s as another asset already matched %s", name, key)
+			continue
+		}
+		seen[key] = true
+
+		uri := info.Assets[name]
+		logrus.Infof("getting sha256 for %s", uri)
+		sha256, err := getSha256ForAsset(uri, info.Signatures)
+		if err != nil {
+			return nil, err
+		}
+
+		platform := generatedPlatform{
+			OS:     goos,
+			Arch:   goarch,
+			URI:    uri,
+			Sha256: sha256,
+			Files:  []index.FileOperation{{From: "*", To: "."}},
+			Bin:    binaryName(goos, fmt.Sprintf("kubectl-%s", info.PluginName)),
+		}
+
+		if existing := findPlatform(info.Existing, goos, goarch); existing != nil {
+			platform.Files = existing.Files
+			platform.Bin = existing.Bin
+		}
+
+		platforms = append(platforms, platform)
+	}
+
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("no release assets matched the naming convention <name>-<os>-<arch>.<tar.gz|zip>")
+	}
+
+	t, err := template.New("generated").Funcs(map[string]interface{}{
+		"quote":  strconv.Quote,
+		"indent": indent,
+	}).Parse(generatedManifestTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, struct {
+		Info      ManifestInfo
+		Platforms []generatedPlatform
+	}{
+		Info:      info,
+		Platforms: platforms,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+//PlatformForAsset detects the krew os and arch for a release asset from its name
+func PlatformForAsset(name string) (string, string, bool) {
+	lower := strings.ToLower(name)
+
+	ext := ""
+	for _, e := range archiveExtensions {
+		if strings.HasSuffix(lower, e) {
+			ext = e
+			break
+		}
+	}
+
+	if ext == "" {
+		return "", "", false
+	}
+
+	//x86_64 would otherwise be split into two tokens
+	base := strings.TrimSuffix(lower, ext)
+	base = strings.ReplaceAll(base, "x86_64", "amd64")
+	base = strings.ReplaceAll(base, "x86-64", "amd64")
+
+	tokens := strings.FieldsFunc(base, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+
+	goos, goarch := "", ""
+	for _, token := range tokens {
+		if o, ok := osTokens[token]; ok && goos == "" {
+			goos = o
+		}
+
+		if a, ok := archTokens[token]; ok && goarch == "" {
+			goarch = a
+		}
+	}
+
+	if goos == "" || goarch == "" {
+		return "", "", false
+	}
+
+	return goos, goarch, true
+}
+
+func findPlatform(plugin *index.Plugin, goos, goarch string) *index.Platform {
+	if plugin == nil {
+		return nil
+	}
+
+	for i, platform := range plugin.Spec.Platforms {
+		if platform.Selector == nil {
+			continue
+		}
+
+		labels := platform.Selector.MatchLabels
+		if labels["os"] == goos && labels["arch"] == goarch {
+			return &plugin.Spec.Platforms[i]
+		}
+	}
+
+	return nil
+}
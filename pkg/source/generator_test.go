@@ -0,0 +1,149 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestPlatformForAsset(t *testing.T) {
+	testcases := []struct {
+		name         string
+		asset        string
+		expectedOS   string
+		expectedArch string
+		expectedOK   bool
+	}{
+		{
+			name:         "os and arch separated by dash",
+			asset:        "kubectl-whoami-linux-amd64.tar.gz",
+			expectedOS:   "linux",
+			expectedArch: "amd64",
+			expectedOK:   true,
+		},
+		{
+			name:         "goreleaser style name with replacements",
+			asset:        "kubectl-whoami_v0.0.2_Darwin_x86_64.tar.gz",
+			expectedOS:   "darwin",
+			expectedArch: "amd64",
+			expectedOK:   true,
+		},
+		{
+			name:         "windows zip",
+			asset:        "whoami_windows_386.zip",
+			expectedOS:   "windows",
+			expectedArch: "386",
+			expectedOK:   true,
+		},
+		{
+			name:         "arm64 as aarch64",
+			asset:        "whoami-linux-aarch64.tgz",
+			expectedOS:   "linux",
+			expectedArch: "arm64",
+			expectedOK:   true,
+		},
+		{
+			name:  "not an archive",
+			asset: "whoami-linux-amd64",
+		},
+		{
+			name:  "checksums file",
+			asset: "checksums.txt",
+		},
+		{
+			name:  "no arch in name",
+			asset: "whoami-linux.tar.gz",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			goos, goarch, ok := PlatformForAsset(tc.asset)
+			assert.Equal(t, tc.expectedOS, goos)
+			assert.Equal(t, tc.expectedArch, goarch)
+			assert.Equal(t, tc.expectedOK, ok)
+		})
+	}
+}
+
+func TestGenerateManifest(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	gock.New("https://github.com").
+		Get("/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_linux_amd64.tar.gz").
+		Reply(200).
+		BodyString("linux-amd64")
+
+	gock.New("https://github.com").
+		Get("/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_windows_amd64.zip").
+		Reply(200).
+		BodyString("windows-amd64")
+
+	info := ManifestInfo{
+		PluginName:       "whoami",
+		Version:          "v0.0.2",
+		Homepage:         "https://github.com/rajatjindal/kubectl-whoami",
+		ShortDescription: "Show the subject that's currently authenticated as.",
+		Description:      "This plugin show the subject\nthat's currently authenticated as.\n",
+		Existing: &index.Plugin{
+			Spec: index.PluginSpec{
+				Platforms: []index.Platform{
+					{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "linux", "arch": "amd64"}},
+						Files:    []index.FileOperation{{From: "kubectl-whoami", To: "."}, {From: "LICENSE", To: "."}},
+						Bin:      "kubectl-whoami",
+					},
+				},
+			},
+		},
+		Assets: map[string]string{
+			"kubectl-whoami_windows_amd64.zip":  "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_windows_amd64.zip",
+			"kubectl-whoami_linux_amd64.tar.gz": "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_linux_amd64.tar.gz",
+			"kubectl-whoami_checksums.txt":      "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_checksums.txt",
+		},
+	}
+
+	expected := `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: "whoami"
+spec:
+  version: "v0.0.2"
+  homepage: "https://github.com/rajatjindal/kubectl-whoami"
+  shortDescription: "Show the subject that's currently authenticated as."
+  description: |
+    This plugin show the subject
+    that's currently authenticated as.
+  platforms:
+  - selector:
+      matchLabels:
+        os: linux
+        arch: amd64
+    uri: "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_linux_amd64.tar.gz"
+    sha256: abb35c616421af72198ad7c2aeeef38516f08f6a7afb2a728cf0068a8a712ddc
+    files:
+    - from: "kubectl-whoami"
+      to: "."
+    - from: "LICENSE"
+      to: "."
+    bin: "kubectl-whoami"
+  - selector:
+      matchLabels:
+        os: windows
+        arch: amd64
+    uri: "https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_windows_amd64.zip"
+    sha256: b5c168db719e3a66397ce14e1907340ebdb78b910c98df897c665a1a74804190
+    files:
+    - from: "*"
+      to: "."
+    bin: "kubectl-whoami.exe"
+`
+
+	manifest, err := GenerateManifest(info)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(manifest))
+}
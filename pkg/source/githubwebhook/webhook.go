@@ -0,0 +1,273 @@
+package githubwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/pkg/errors"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/rajatjindal/krew-release-bot/pkg/source/remote"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"sigs.k8s.io/yaml"
+)
+
+//SignatureHeader is the header with the HMAC of the payload, signed using the webhook secret
+const SignatureHeader = "X-Hub-Signature-256"
+
+//DefaultTemplateFile is the template fetched from the plugin repo, at the tag of the release
+const DefaultTemplateFile = ".krew.yaml"
+
+//GithubWebhook handles release events sent by github, for installations of the github app or by webhooks
+//added to repos. Each repo webhook has its own secret, so that its author cannot sign events for other repos
+type GithubWebhook struct {
+	secret      []byte
+	repoSecrets map[string][]byte
+	client      *github.Client
+
+	//TemplateFile is the path of the template in the plugin repo
+	TemplateFile string
+}
+
+//NewGithubWebhook gets new github release webhook instance. The secret is the webhook secret of the github app,
+//and repoSecrets are the secrets of webhooks added to repos, by <owner>/<repo>. The token is used to fetch
+//the release and the template from the plugin repo
+func NewGithubWebhook(secret string, repoSecrets map[string]string, token string) (*GithubWebhook, error) {
+	if secret == "" && len(repoSecrets) == 0 {
+		return nil, fmt.Errorf("webhook secret of the github app, or of repos, is required")
+	}
+
+	secrets := map[string][]byte{}
+	for repo, repoSecret := range repoSecrets {
+		if repoSecret == "" {
+			return nil, fmt.Errorf("webhook secret of repo %s is empty", repo)
+		}
+
+		secrets[strings.ToLower(repo)] = []byte(repoSecret)
+	}
+
+	httpClient := http.DefaultClient
+	if token != "" {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		httpClient = oauth2.NewClient(context.TODO(), ts)
+	}
+
+	return &GithubWebhook{
+		secret:       []byte(secret),
+		repoSecrets:  secrets,
+		client:       github.NewClient(httpClient),
+		TemplateFile: DefaultTemplateFile,
+	}, nil
+}
+
+//Parse validates the signature of the release event, using the secret of the github app for events sent for its
+//installations, or the secret of the repo otherwise. The manifest is rendered later using Render, as github does not wait for it
+func (w *GithubWebhook) Parse(r *http.Request) (*source.ReleaseRequest, error) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	//the payload is not trusted until the signature is validated, it is only used to find the secret
+	event := &github.ReleaseEvent{}
+	err = json.Unmarshal(body, event)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := w.secretForEvent(event)
+	if err != nil {
+		return nil, &releaser.ReleaseError{Code: source.ErrorCodeUnauthorized, Err: err}
+	}
+
+	err = validateSignature(r.Header.Get(SignatureHeader), body, secret)
+	if err != nil {
+		return nil, &releaser.ReleaseError{Code: source.ErrorCodeUnauthorized, Err: err}
+	}
+
+	eventType := github.WebHookType(r)
+	if eventType != "release" {
+		return nil, errors.Wrapf(source.ErrEventIgnored, "event %q is not a release event", eventType)
+	}
+
+	release := event.GetRelease()
+	if event.GetAction() != "published" || release.GetDraft() || release.GetPrerelease() {
+		return nil, errors.Wrapf(source.ErrEventIgnored, "release %q with action %q is not a published release", release.GetTagName(), event.GetAction())
+	}
+
+	if event.GetRepo().GetOwner().GetLogin() == "" || event.GetRepo().GetName() == "" || release.GetTagName() == "" {
+		return nil, fmt.Errorf("repository or tag not found in payload of release event")
+	}
+
+	request := &source.ReleaseRequest{
+		TagName:            release.GetTagName(),
+		PluginOwner:        event.GetRepo().GetOwner().GetLogin(),
+		PluginRepo:         event.GetRepo().GetName(),
+		PluginReleaseActor: event.GetSender().GetLogin(),
+		TemplateFile:       w.TemplateFile,
+	}
+
+	logrus.Infof("got release %q of %s/%s", request.TagName, request.PluginOwner, request.PluginRepo)
+	return request, nil
+}
+
+//Render fetches the release and the repo from github, instead of using them from the payload, and renders
+//the template at the tag of the release. Only the uris allowed by the policy are downloaded
+func (w *GithubWebhook) Render(request *source.ReleaseRequest, policy *releaser.Policy) error {
+	release, _, err := w.client.Repositories.GetReleaseByTag(context.TODO(), request.PluginOwner, request.PluginRepo, request.TagName)
+	if err != nil {
+		return &releaser.ReleaseError{Code: source.ErrorCodeUpstreamError, Err: errors.Wrapf(err, "fetching release with tag %q", request.TagName)}
+	}
+
+	if release.GetDraft() || release.GetPrerelease() {
+		return &releaser.ReleaseError{Code: source.ErrorCodeInvalidRequest, Err: fmt.Errorf("release with tag %q is not a published release", request.TagName)}
+	}
+
+	repo, _, err := w.client.Repositories.Get(context.TODO(), request.PluginOwner, request.PluginRepo)
+	if err != nil {
+		return &releaser.ReleaseError{Code: source.ErrorCodeUpstreamError, Err: errors.Wrapf(err, "fetching repo %s/%s", request.PluginOwner, request.PluginRepo)}
+	}
+
+	pluginName, manifest, err := remote.RenderTemplate(w.client, request, release, repo, policy)
+	if err != nil {
+		return err
+	}
+
+	request.PluginName = pluginName
+	request.ProcessedTemplate = manifest
+	return nil
+}
+
+//ReportFailure opens an issue in the plugin repo with the error, unless one is already open for the tag, as the
+//event was acknowledged before the release failed. Errors when opening the issue are only logged
+func (w *GithubWebhook) ReportFailure(request *source.ReleaseRequest, err error) {
+	issue, reportErr := w.openIssue(request, err)
+	if reportErr != nil {
+		logrus.Warnf("reporting failed release of tag %q to %s/%s failed. error: %v", request.TagName, request.PluginOwner, request.PluginRepo, reportErr)
+		return
+	}
+
+	logrus.Infof("reported failed release of tag %q of %s/%s in %s", request.TagName, request.PluginOwner, request.PluginRepo, issue)
+}
+
+func (w *GithubWebhook) openIssue(request *source.ReleaseRequest, err error) (string, error) {
+	title := fmt.Sprintf("Releasing %s to krew-index failed", request.TagName)
+
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		issues, resp, listErr := w.client.Issues.ListByRepo(context.TODO(), request.PluginOwner, request.PluginRepo, opts)
+		if listErr != nil {
+			return "", listErr
+		}
+
+		for _, issue := range issues {
+			if issue.GetTitle() == title {
+				return issue.GetHTMLURL(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	issue, _, createErr := w.client.Issues.Create(context.TODO(), request.PluginOwner, request.PluginRepo, &github.IssueRequest{
+		Title: github.String(title),
+		Body:  github.String(failureBody(request, err)),
+	})
+	if createErr != nil {
+		return "", createErr
+	}
+
+	return issue.GetHTMLURL(), nil
+}
+
+func failureBody(request *source.ReleaseRequest, err error) string {
+	code := source.ErrorCodeInternalError
+	if releaseErr, ok := err.(*releaser.ReleaseError); ok {
+		code = releaseErr.Code
+	}
+
+	return strings.Join([]string{
+		fmt.Sprintf("hey %s maintainers,", request.PluginRepo),
+		"",
+		fmt.Sprintf("I am [krew-release-bot](https://github.com/rajatjindal/krew-release-bot). I received the release event for tag %s, but could not open the PR in [krew-index](https://github.com/kubernetes-sigs/krew-index):", request.TagName),
+		"",
+		"```",
+		fmt.Sprintf("%s: %s", code, err.Error()),
+		"```",
+		"",
+		fmt.Sprintf("Please fix the error, and publish the release again or release a new version. The template is read from `%s` at the tag.", request.TemplateFile),
+		"",
+		"Thanks,",
+		"[krew-release-bot](https://github.com/rajatjindal/krew-release-bot)",
+	}, "\n")
+}
+
+//secretForEvent gets the secret the event must be signed with. Events for installations of the github app are
+//signed using the secret of the app, and events of repo webhooks using the secret of the repo in the payload,
+//which is the repo released, so that the secret of one repo cannot be used to release another repo
+func (w *GithubWebhook) secretForEvent(event *github.ReleaseEvent) ([]byte, error) {
+	owner, repo := event.GetRepo().GetOwner().GetLogin(), event.GetRepo().GetName()
+	if event.GetInstallation().GetID() != 0 || owner == "" || repo == "" {
+		if len(w.secret) == 0 {
+			return nil, fmt.Errorf("events of the github app are not accepted, as its webhook secret is not configured")
+		}
+
+		return w.secret, nil
+	}
+
+	secret, ok := w.repoSecrets[strings.ToLower(fmt.Sprintf("%s/%s", owner, repo))]
+	if !ok {
+		return nil, fmt.Errorf("webhook secret of repo %s/%s is not configured. install the github app, or ask krew-release-bot maintainers to add the secret of the repo webhook", owner, repo)
+	}
+
+	return secret, nil
+}
+
+//validateSignature checks the HMAC of the payload, which github signs using the webhook secret
+func validateSignature(signature string, payload []byte, secret []byte) error {
+	if signature == "" {
+		return fmt.Errorf("header %s not found", SignatureHeader)
+	}
+
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("header %s is incorrect format. expected format sha256=<hex>", SignatureHeader)
+	}
+
+	err := github.ValidateSignature(signature, payload, secret)
+	if err != nil {
+		return errors.Wrapf(err, "validating %s", SignatureHeader)
+	}
+
+	return nil
+}
+
+//LoadRepoSecrets loads the webhook secrets of repos from the yaml file, mapping <owner>/<repo> to the secret
+func LoadRepoSecrets(file string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := map[string]string{}
+	err = yaml.UnmarshalStrict(data, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repo secrets file %s. error: %v", file, err)
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,385 @@
+package githubwebhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/pkg/errors"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const secret = "some-secret"
+
+const repoSecret = "some-repo-secret"
+
+func sign(payload []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func templateContents(t *testing.T, templateFile string) string {
+	data, err := ioutil.ReadFile(templateFile)
+	assert.Nil(t, err)
+
+	return fmt.Sprintf(`{"type": "file", "encoding": "base64", "content": %q}`, base64.StdEncoding.EncodeToString(data))
+}
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		name            string
+		eventFile       string
+		eventType       string
+		signature       func(payload []byte) string
+		expectedIgnored bool
+		expectedCode    string
+		expectedError   string
+	}{
+		{
+			name:      "published release",
+			eventFile: "data/release.json",
+			eventType: "release",
+		},
+		{
+			name:          "signature header missing",
+			eventFile:     "data/release.json",
+			eventType:     "release",
+			signature:     func(payload []byte) string { return "" },
+			expectedCode:  source.ErrorCodeUnauthorized,
+			expectedError: "header X-Hub-Signature-256 not found",
+		},
+		{
+			name:          "signed with another secret",
+			eventFile:     "data/release.json",
+			eventType:     "release",
+			signature:     func(payload []byte) string { return sign(payload, "another-secret") },
+			expectedCode:  source.ErrorCodeUnauthorized,
+			expectedError: "validating X-Hub-Signature-256: payload signature check failed",
+		},
+		{
+			name:          "sha1 signature",
+			eventFile:     "data/release.json",
+			eventType:     "release",
+			signature:     func(payload []byte) string { return "sha1=0123" },
+			expectedCode:  source.ErrorCodeUnauthorized,
+			expectedError: "header X-Hub-Signature-256 is incorrect format. expected format sha256=<hex>",
+		},
+		{
+			name:      "event from a repo webhook signed with the secret of the repo",
+			eventFile: "data/release-repo-webhook.json",
+			eventType: "release",
+			signature: func(payload []byte) string { return sign(payload, repoSecret) },
+		},
+		{
+			name:          "event from a repo webhook signed with the secret of the app",
+			eventFile:     "data/release-repo-webhook.json",
+			eventType:     "release",
+			expectedCode:  source.ErrorCodeUnauthorized,
+			expectedError: "validating X-Hub-Signature-256: payload signature check failed",
+		},
+		{
+			name:          "event from a repo webhook signed with the secret of another repo",
+			eventFile:     "data/release-unknown-repo-webhook.json",
+			eventType:     "release",
+			signature:     func(payload []byte) string { return sign(payload, repoSecret) },
+			expectedCode:  source.ErrorCodeUnauthorized,
+			expectedError: "webhook secret of repo someone-else/kubectl-whoami is not configured. install the github app, or ask krew-release-bot maintainers to add the secret of the repo webhook",
+		},
+		{
+			name:            "ping event is ignored",
+			eventFile:       "data/ping.json",
+			eventType:       "ping",
+			expectedIgnored: true,
+		},
+		{
+			name:            "release which is not published is ignored",
+			eventFile:       "data/release-created.json",
+			eventType:       "release",
+			expectedIgnored: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			payload, err := ioutil.ReadFile(tc.eventFile)
+			assert.Nil(t, err)
+
+			signature := sign(payload, secret)
+			if tc.signature != nil {
+				signature = tc.signature(payload)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/github-release-webhook", bytes.NewReader(payload))
+			r.Header.Set("X-GitHub-Event", tc.eventType)
+			if signature != "" {
+				r.Header.Set(SignatureHeader, signature)
+			}
+
+			hook := &GithubWebhook{
+				secret:       []byte(secret),
+				repoSecrets:  map[string][]byte{"foo-bar/my-awesome-plugin": []byte(repoSecret)},
+				client:       github.NewClient(nil),
+				TemplateFile: DefaultTemplateFile,
+			}
+			request, err := hook.Parse(r)
+
+			switch {
+			case tc.expectedIgnored:
+				assert.Equal(t, source.ErrEventIgnored, errors.Cause(err))
+			case tc.expectedError != "":
+				assertReleaseError(t, tc.expectedCode, tc.expectedError, err)
+			default:
+				assert.Nil(t, err)
+				assert.Equal(t, "v0.0.2", request.TagName)
+				assert.Equal(t, "foo-bar", request.PluginOwner)
+				assert.Equal(t, "my-awesome-plugin", request.PluginRepo)
+				assert.Equal(t, "rajatjindal", request.PluginReleaseActor)
+				assert.Equal(t, DefaultTemplateFile, request.TemplateFile)
+
+				//the manifest is rendered later, after the event is acknowledged
+				assert.Empty(t, request.ProcessedTemplate)
+			}
+
+			//github api is not called before the event is acknowledged
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	testcases := []struct {
+		name             string
+		setupMocks       func(t *testing.T)
+		expectedManifest string
+		expectedCode     string
+		expectedError    string
+	}{
+		{
+			name: "published release",
+			setupMocks: func(t *testing.T) {
+				mockRelease(releasePublished)
+				mockRepo()
+				mockTemplate(t, "data/krew.yaml")
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v0.0.2").
+					Reply(200).
+					BodyString("8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e")
+			},
+			expectedManifest: `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: my-awesome-plugin
+spec:
+  version: v0.0.2
+  homepage: https://github.com/foo-bar/my-awesome-plugin
+  platforms:
+  - selector:
+      matchLabels:
+        os: linux
+        arch: amd64
+    uri: https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2/linux-amd64-v0.0.2.tar.gz
+    sha256: 2c9ac7d5ae2ec6ad5f7ac6fd8d7c9d5e0e0e4b2ddb9d7a9c6c8b3f2e7b5a9d1c
+    bin: my-awesome-plugin
+  shortDescription: This is the most awesome kubectl plugin
+  description: |
+    Built from commit 8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e
+`,
+		},
+		{
+			name: "release was changed to draft after the event",
+			setupMocks: func(t *testing.T) {
+				mockRelease(releaseDraft)
+			},
+			expectedCode:  source.ErrorCodeInvalidRequest,
+			expectedError: `release with tag "v0.0.2" is not a published release`,
+		},
+		{
+			name: "template not found at tag",
+			setupMocks: func(t *testing.T) {
+				mockRelease(releasePublished)
+				mockRepo()
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/contents/.krew.yaml").
+					Reply(404).
+					JSON(`{"message": "Not Found"}`)
+			},
+			expectedCode:  source.ErrorCodeInvalidManifest,
+			expectedError: "fetching template .krew.yaml at tag \"v0.0.2\": GET https://api.github.com/repos/foo-bar/my-awesome-plugin/contents/.krew.yaml?ref=v0.0.2: 404 Not Found []",
+		},
+		{
+			name: "github is down",
+			setupMocks: func(t *testing.T) {
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+					Reply(502).
+					JSON(`{"message": "Server Error"}`)
+			},
+			expectedCode:  source.ErrorCodeUpstreamError,
+			expectedError: "fetching release with tag \"v0.0.2\": GET https://api.github.com/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2: 502 Server Error []",
+		},
+		{
+			name: "template downloads uri which is not a release asset",
+			setupMocks: func(t *testing.T) {
+				mockRelease(releasePublished)
+				mockRepo()
+				mockTemplate(t, "data/krew-evil-host.yaml")
+
+				gock.New("https://api.github.com").
+					Get("/repos/foo-bar/my-awesome-plugin/commits/v0.0.2").
+					Reply(200).
+					BodyString("8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e")
+			},
+			expectedCode:  source.ErrorCodeInvalidManifest,
+			expectedError: `template: .krew.yaml:13:7: executing ".krew.yaml" at <addURIAndSha "https://169.254.169.254/latest/meta-data/linux-amd64.tar.gz" .TagName>: error calling addURIAndSha: uri "https://169.254.169.254/latest/meta-data/linux-amd64.tar.gz" is not a release asset of https://github.com/foo-bar/my-awesome-plugin/releases/download/v0.0.2, and host "169.254.169.254" is not in the allowed hosts for the plugin`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			tc.setupMocks(t)
+
+			request := &source.ReleaseRequest{
+				TagName:            "v0.0.2",
+				PluginOwner:        "foo-bar",
+				PluginRepo:         "my-awesome-plugin",
+				PluginReleaseActor: "rajatjindal",
+				TemplateFile:       DefaultTemplateFile,
+			}
+
+			hook := &GithubWebhook{secret: []byte(secret), client: github.NewClient(nil), TemplateFile: DefaultTemplateFile}
+			err := hook.Render(request, nil)
+
+			if tc.expectedError != "" {
+				assertReleaseError(t, tc.expectedCode, tc.expectedError, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, "my-awesome-plugin", request.PluginName)
+				assert.Equal(t, tc.expectedManifest, string(request.ProcessedTemplate))
+			}
+
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func assertReleaseError(t *testing.T, expectedCode, expectedError string, err error) {
+	assert.EqualError(t, err, expectedError)
+	releaseErr, ok := err.(*releaser.ReleaseError)
+	assert.True(t, ok)
+	if ok {
+		assert.Equal(t, expectedCode, releaseErr.Code)
+	}
+}
+
+func mockRelease(release string) {
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/my-awesome-plugin/releases/tags/v0.0.2").
+		Reply(200).
+		BodyString(release)
+}
+
+func mockRepo() {
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/my-awesome-plugin").
+		Reply(200).
+		BodyString(`{"name": "my-awesome-plugin", "description": "This is the most awesome kubectl plugin"}`)
+}
+
+func mockTemplate(t *testing.T, templateFile string) {
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/my-awesome-plugin/contents/.krew.yaml").
+		MatchParam("ref", "v0.0.2").
+		Reply(200).
+		BodyString(templateContents(t, templateFile))
+}
+
+func TestNewGithubWebhook(t *testing.T) {
+	_, err := NewGithubWebhook("", nil, "")
+	assert.EqualError(t, err, "webhook secret of the github app, or of repos, is required")
+
+	_, err = NewGithubWebhook("", map[string]string{"foo-bar/my-awesome-plugin": ""}, "")
+	assert.EqualError(t, err, "webhook secret of repo foo-bar/my-awesome-plugin is empty")
+
+	hook, err := NewGithubWebhook("", map[string]string{"Foo-Bar/My-Awesome-Plugin": repoSecret}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(repoSecret), hook.repoSecrets["foo-bar/my-awesome-plugin"])
+}
+
+func TestLoadRepoSecrets(t *testing.T) {
+	secrets, err := LoadRepoSecrets("data/repo-secrets.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"foo-bar/my-awesome-plugin": "repo-secret"}, secrets)
+}
+
+const releasePublished = `{
+	"id": 22569944,
+	"tag_name": "v0.0.2",
+	"name": "v0.0.2",
+	"draft": false,
+	"prerelease": false
+}`
+
+const releaseDraft = `{
+	"id": 22569944,
+	"tag_name": "v0.0.2",
+	"name": "v0.0.2",
+	"draft": true,
+	"prerelease": false
+}`
+
+func TestReportFailure(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	request := &source.ReleaseRequest{
+		TagName:      "v0.0.2",
+		PluginOwner:  "foo-bar",
+		PluginRepo:   "my-awesome-plugin",
+		TemplateFile: DefaultTemplateFile,
+	}
+
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/my-awesome-plugin/issues").
+		MatchParam("state", "open").
+		Reply(200).
+		JSON(`[{"title": "some other issue", "html_url": "https://github.com/foo-bar/my-awesome-plugin/issues/1"}]`)
+	gock.New("https://api.github.com").
+		Post("/repos/foo-bar/my-awesome-plugin/issues").
+		BodyString(`.*"title":"Releasing v0.0.2 to krew-index failed".*invalid_manifest: template .krew.yaml not found.*`).
+		Reply(201).
+		JSON(`{"html_url": "https://github.com/foo-bar/my-awesome-plugin/issues/2"}`)
+
+	hook := &GithubWebhook{secret: []byte(secret), client: github.NewClient(nil), TemplateFile: DefaultTemplateFile}
+	hook.ReportFailure(request, &releaser.ReleaseError{Code: source.ErrorCodeInvalidManifest, Err: fmt.Errorf("template .krew.yaml not found")})
+	assert.True(t, gock.IsDone())
+
+	//no other issue is opened while one is open for the tag
+	gock.New("https://api.github.com").
+		Get("/repos/foo-bar/my-awesome-plugin/issues").
+		MatchParam("state", "open").
+		Reply(200).
+		JSON(`[{"title": "Releasing v0.0.2 to krew-index failed", "html_url": "https://github.com/foo-bar/my-awesome-plugin/issues/2"}]`)
+
+	hook.ReportFailure(request, &releaser.ReleaseError{Code: source.ErrorCodeInvalidManifest, Err: fmt.Errorf("template .krew.yaml not found")})
+	assert.True(t, gock.IsDone())
+}
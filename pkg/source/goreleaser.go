@@ -0,0 +1,501 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	goreleaserArtifactsFile = "dist/artifacts.json"
+
+	//goreleaserMetadataFile has the tag of the release goreleaser built the artifacts for
+	goreleaserMetadataFile = "dist/metadata.json"
+
+	//default archive name template used by goreleaser when none is configured
+	goreleaserDefaultNameTemplate = "{{ .ProjectName }}_{{ .Version }}_{{ .Os }}_{{ .Arch }}{{ if .Arm }}v{{ .Arm }}{{ end }}"
+)
+
+var goreleaserConfigFiles = []string{
+	".goreleaser.yml",
+	".goreleaser.yaml",
+	"goreleaser.yml",
+	"goreleaser.yaml",
+}
+
+//krew only installs plugins on these operating systems
+var krewSupportedOS = map[string]bool{
+	"darwin":  true,
+	"linux":   true,
+	"windows": true,
+}
+
+//goreleaserArchive is one os/arch archive produced by goreleaser
+type goreleaserArchive struct {
+	Name      string
+	OS        string
+	Arch      string
+	Binary    string
+	WrappedIn string
+}
+
+//goreleaserArtifact is an entry in goreleaser's dist/artifacts.json
+type goreleaserArtifact struct {
+	Name   string `json:"name"`
+	Goos   string `json:"goos"`
+	Goarch string `json:"goarch"`
+	Goarm  string `json:"goarm"`
+	Type   string `json:"type"`
+	Extra  struct {
+		Binary    string   `json:"Binary"`
+		Binaries  []string `json:"Binaries"`
+		WrappedIn string   `json:"WrappedIn"`
+	} `json:"extra"`
+}
+
+//goreleaserMetadata is the subset of dist/metadata.json used to check the artifacts are for the tag
+type goreleaserMetadata struct {
+	Tag     string `json:"tag"`
+	Version string `json:"version"`
+}
+
+//goreleaserConfig is the subset of .goreleaser.yml used to predict archive names
+type goreleaserConfig struct {
+	ProjectName string                    `json:"project_name"`
+	Builds      []goreleaserBuildConfig   `json:"builds"`
+	Archives    []goreleaserArchiveConfig `json:"archives"`
+}
+
+type goreleaserBuildConfig struct {
+	ID     string        `json:"id"`
+	Binary string        `json:"binary"`
+	Goos   []string      `json:"goos"`
+	Goarch []string      `json:"goarch"`
+	Goarm  []interface{} `json:"goarm"`
+	Ignore []struct {
+		Goos   string      `json:"goos"`
+		Goarch string      `json:"goarch"`
+		Goarm  interface{} `json:"goarm"`
+	} `json:"ignore"`
+}
+
+type goreleaserArchiveConfig struct {
+	Builds          []string          `json:"builds"`
+	NameTemplate    string            `json:"name_template"`
+	Format          string            `json:"format"`
+	Replacements    map[string]string `json:"replacements"`
+	WrapInDirectory interface{}       `json:"wrap_in_directory"`
+	FormatOverrides []struct {
+		Goos   string `json:"goos"`
+		Format string `json:"format"`
+	} `json:"format_overrides"`
+}
+
+//addPlatformsFromGoreleaser renders krew platform entries for every archive goreleaser produced.
+//urlPrefix is the download url of the release (may contain {{ .TagName }}), to which the archive
+//name is appended. The first line is not indented, and following lines are indented for use as
+//an item of "  platforms:"
+func addPlatformsFromGoreleaser(urlPrefix, tag string, opts TemplateOptions) (string, error) {
+	archives, err := getGoreleaserArchives(opts.Workdir, tag)
+	if err != nil {
+		return "", err
+	}
+
+	if len(archives) == 0 {
+		return "", fmt.Errorf("no archives for krew supported platforms found in goreleaser config in %q", opts.Workdir)
+	}
+
+	prefix, err := renderTagName(urlPrefix, tag)
+	if err != nil {
+		return "", err
+	}
+
+	entries := []string{}
+	for _, archive := range archives {
+		uri := fmt.Sprintf("%s/%s", strings.TrimSuffix(prefix, "/"), archive.Name)
+		logrus.Infof("getting sha256 for %s", uri)
+		sha256, err := opts.sha256ForAsset(uri)
+		if err != nil {
+			return "", err
+		}
+
+		entries = append(entries, formatPlatform(archive, uri, sha256))
+	}
+
+	return strings.Join(entries, "\n  "), nil
+}
+
+func formatPlatform(archive goreleaserArchive, uri, sha256 string) string {
+	from := "*"
+	if archive.WrappedIn != "" {
+		from = fmt.Sprintf("%s/*", archive.WrappedIn)
+	}
+
+	return fmt.Sprintf(`- selector:
+      matchLabels:
+        os: %s
+        arch: %s
+    uri: %s
+    sha256: %s
+    files:
+    - from: %q
+      to: "."
+    bin: %s`, archive.OS, archive.Arch, uri, sha256, from, archive.Binary)
+}
+
+func renderTagName(text, tag string) (string, error) {
+	t := struct {
+		TagName string
+	}{
+		TagName: tag,
+	}
+
+	temp, err := template.New("url").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	err = temp.Execute(buf, t)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+//getGoreleaserArchives prefers the exact list of archives in dist/artifacts.json
+//and falls back to predicting them from .goreleaser.yml
+func getGoreleaserArchives(workdir, tag string) ([]goreleaserArchive, error) {
+	artifactsFile := filepath.Join(workdir, goreleaserArtifactsFile)
+	if _, err := os.Stat(artifactsFile); err == nil {
+		logrus.Infof("using goreleaser artifacts from %s", artifactsFile)
+		return archivesFromArtifacts(artifactsFile, tag)
+	}
+
+	for _, name := range goreleaserConfigFiles {
+		configFile := filepath.Join(workdir, name)
+		if _, err := os.Stat(configFile); err == nil {
+			logrus.Infof("using goreleaser config from %s", configFile)
+			return archivesFromConfig(configFile, tag)
+		}
+	}
+
+	return nil, fmt.Errorf("neither %s nor .goreleaser.yml found in %q", goreleaserArtifactsFile, workdir)
+}
+
+func archivesFromArtifacts(file, tag string) ([]goreleaserArchive, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []goreleaserArtifact{}
+	err = json.Unmarshal(data, &artifacts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s. error: %v", file, err)
+	}
+
+	err = checkArtifactsTag(filepath.Join(filepath.Dir(filepath.Dir(file)), goreleaserMetadataFile), tag)
+	if err != nil {
+		return nil, err
+	}
+
+	//krew can only select on os/arch, so only one archive is used for each of them.
+	//for arm, the lowest goarm is used as it runs on newer arm versions as well
+	selected := map[string]goreleaserArtifact{}
+	keys := []string{}
+	for _, artifact := range artifacts {
+		if artifact.Type != "Archive" || !krewSupportedOS[artifact.Goos] {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", artifact.Goos, artifact.Goarch)
+		existing, ok := selected[key]
+		if !ok {
+			keys = append(keys, key)
+			selected[key] = artifact
+			continue
+		}
+
+		if artifact.Goarch == "arm" && artifact.Goarm < existing.Goarm {
+			logrus.Infof("using archive %s instead of %s for %s", artifact.Name, existing.Name, key)
+			selected[key] = artifact
+			continue
+		}
+
+		logrus.Infof("skipping archive %s as archive %s is already used for %s", artifact.Name, existing.Name, key)
+	}
+
+	archives := []goreleaserArchive{}
+	for _, key := range keys {
+		artifact := selected[key]
+		binary := artifact.Extra.Binary
+		if len(artifact.Extra.Binaries) > 0 {
+			binary = artifact.Extra.Binaries[0]
+		}
+
+		if binary == "" {
+			return nil, fmt.Errorf("no binary found for archive %s in %s", artifact.Name, file)
+		}
+
+		archives = append(archives, goreleaserArchive{
+			Name:      artifact.Name,
+			OS:        artifact.Goos,
+			Arch:      artifact.Goarch,
+			Binary:    binaryName(artifact.Goos, binary),
+			WrappedIn: artifact.Extra.WrappedIn,
+		})
+	}
+
+	sortArchives(archives)
+	return archives, nil
+}
+
+//checkArtifactsTag checks that the artifacts in dist are built for the tag, using the tag in dist/metadata.json,
+//so that archives left over in dist from an earlier release are not used. Older versions of goreleaser
+//do not write dist/metadata.json, and the artifacts are then used as is
+func checkArtifactsTag(file, tag string) error {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		logrus.Warnf("%s not found, cannot check that the goreleaser artifacts are built for tag %s", goreleaserMetadataFile, tag)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	metadata := goreleaserMetadata{}
+	err = json.Unmarshal(data, &metadata)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s. error: %v", file, err)
+	}
+
+	if metadata.Tag != tag {
+		return fmt.Errorf("goreleaser artifacts in %s are built for tag %q, expected %q", filepath.Dir(file), metadata.Tag, tag)
+	}
+
+	return nil
+}
+
+func archivesFromConfig(file, tag string) ([]goreleaserArchive, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &goreleaserConfig{}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s. error: %v", file, err)
+	}
+
+	if config.ProjectName == "" {
+		return nil, fmt.Errorf("project_name must be set in %s to generate platforms", file)
+	}
+
+	builds := config.Builds
+	if len(builds) == 0 {
+		builds = []goreleaserBuildConfig{{}}
+	}
+
+	archiveConfig := goreleaserArchiveConfig{}
+	if len(config.Archives) > 0 {
+		archiveConfig = config.Archives[0]
+	}
+
+	nameTemplate := archiveConfig.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = goreleaserDefaultNameTemplate
+	}
+
+	t, err := template.New("name_template").Funcs(map[string]interface{}{
+		"tolower":    strings.ToLower,
+		"toupper":    strings.ToUpper,
+		"title":      strings.Title,
+		"trimprefix": strings.TrimPrefix,
+		"replace":    strings.ReplaceAll,
+	}).Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archive name_template in %s. error: %v", file, err)
+	}
+
+	seen := map[string]bool{}
+	archives := []goreleaserArchive{}
+	for _, build := range builds {
+		id := build.ID
+		if id == "" {
+			id = config.ProjectName
+		}
+
+		if len(archiveConfig.Builds) > 0 && !Contains(archiveConfig.Builds, id) {
+			continue
+		}
+
+		binary := build.Binary
+		if binary == "" {
+			binary = config.ProjectName
+		}
+
+		for _, target := range buildTargets(build) {
+			if !krewSupportedOS[target.OS] {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s", target.OS, target.Arch)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			values := map[string]string{
+				"ProjectName": config.ProjectName,
+				"Binary":      binary,
+				"Tag":         tag,
+				"Version":     strings.TrimPrefix(tag, "v"),
+				"Os":          replacement(archiveConfig.Replacements, target.OS),
+				"Arch":        replacement(archiveConfig.Replacements, target.Arch),
+				"Arm":         target.Arm,
+			}
+
+			buf := new(bytes.Buffer)
+			err = t.Execute(buf, values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to execute archive name_template in %s. error: %v", file, err)
+			}
+
+			format := archiveFormat(archiveConfig, target.OS)
+			if format == "binary" {
+				return nil, fmt.Errorf("archive format binary for %s in %s is not supported. krew can only install plugins from tar.gz or zip archives", target.OS, file)
+			}
+
+			wrappedIn := ""
+			if wrap, ok := archiveConfig.WrapInDirectory.(bool); ok && wrap {
+				wrappedIn = buf.String()
+			} else if wrap, ok := archiveConfig.WrapInDirectory.(string); ok && wrap != "" {
+				wrappedIn = wrap
+			}
+
+			archives = append(archives, goreleaserArchive{
+				Name:      fmt.Sprintf("%s.%s", buf.String(), format),
+				OS:        target.OS,
+				Arch:      target.Arch,
+				Binary:    binaryName(target.OS, binary),
+				WrappedIn: wrappedIn,
+			})
+		}
+	}
+
+	sortArchives(archives)
+	return archives, nil
+}
+
+type buildTarget struct {
+	OS   string
+	Arch string
+	Arm  string
+}
+
+//buildTargets returns the os/arch combinations goreleaser builds for the given build config
+func buildTargets(build goreleaserBuildConfig) []buildTarget {
+	goos := build.Goos
+	if len(goos) == 0 {
+		goos = []string{"linux", "darwin"}
+	}
+
+	goarch := build.Goarch
+	if len(goarch) == 0 {
+		goarch = []string{"386", "amd64"}
+	}
+
+	//krew can only select on arch, so only one arm version is used
+	goarm := "6"
+	if len(build.Goarm) > 0 {
+		goarm = fmt.Sprint(build.Goarm[0])
+	}
+
+	targets := []buildTarget{}
+	for _, o := range goos {
+		for _, arch := range goarch {
+			target := buildTarget{OS: o, Arch: arch}
+			if arch == "arm" {
+				target.Arm = goarm
+			}
+
+			ignored := false
+			for _, ignore := range build.Ignore {
+				if ignore.Goos == o && ignore.Goarch == arch && (ignore.Goarm == nil || fmt.Sprint(ignore.Goarm) == target.Arm) {
+					ignored = true
+					break
+				}
+			}
+
+			if !ignored {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	return targets
+}
+
+func archiveFormat(config goreleaserArchiveConfig, goos string) string {
+	for _, override := range config.FormatOverrides {
+		if override.Goos == goos {
+			return override.Format
+		}
+	}
+
+	if config.Format != "" {
+		return config.Format
+	}
+
+	return "tar.gz"
+}
+
+func binaryName(goos, binary string) string {
+	if goos == "windows" && !strings.HasSuffix(binary, ".exe") {
+		return fmt.Sprintf("%s.exe", binary)
+	}
+
+	return binary
+}
+
+func replacement(replacements map[string]string, key string) string {
+	if r, ok := replacements[key]; ok {
+		return r
+	}
+
+	return key
+}
+
+func sortArchives(archives []goreleaserArchive) {
+	sort.Slice(archives, func(i, j int) bool {
+		if archives[i].OS != archives[j].OS {
+			return archives[i].OS < archives[j].OS
+		}
+
+		return archives[i].Arch < archives[j].Arch
+	})
+}
+
+//Contains checks if the item is in the list
+func Contains(list []string, item string) bool {
+	for _, l := range list {
+		if l == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,121 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestGetGoreleaserArchives(t *testing.T) {
+	testcases := []struct {
+		name             string
+		workdir          string
+		expectedArchives []goreleaserArchive
+		expectedError    string
+	}{
+		{
+			name:    "archives from dist/artifacts.json",
+			workdir: "data/goreleaser-artifacts",
+			expectedArchives: []goreleaserArchive{
+				{Name: "kubectl-whoami_v0.0.2_linux_amd64.tar.gz", OS: "linux", Arch: "amd64", Binary: "kubectl-whoami"},
+				{Name: "kubectl-whoami_v0.0.2_windows_amd64.zip", OS: "windows", Arch: "amd64", Binary: "kubectl-whoami.exe"},
+			},
+		},
+		{
+			name:    "archives from .goreleaser.yml",
+			workdir: "data/goreleaser-config",
+			expectedArchives: []goreleaserArchive{
+				{Name: "kubectl-whoami_v0.0.2_Darwin_amd64.tar.gz", OS: "darwin", Arch: "amd64", Binary: "kubectl-whoami"},
+				{Name: "kubectl-whoami_v0.0.2_linux_amd64.tar.gz", OS: "linux", Arch: "amd64", Binary: "kubectl-whoami"},
+				{Name: "kubectl-whoami_v0.0.2_linux_armv7.tar.gz", OS: "linux", Arch: "arm", Binary: "kubectl-whoami"},
+				{Name: "kubectl-whoami_v0.0.2_windows_amd64.zip", OS: "windows", Arch: "amd64", Binary: "kubectl-whoami.exe"},
+				{Name: "kubectl-whoami_v0.0.2_windows_armv7.zip", OS: "windows", Arch: "arm", Binary: "kubectl-whoami.exe"},
+			},
+		},
+		{
+			name:    "one archive per os/arch from dist/artifacts.json",
+			workdir: "data/goreleaser-duplicates",
+			expectedArchives: []goreleaserArchive{
+				{Name: "kubectl-whoami_v0.0.2_linux_amd64.tar.gz", OS: "linux", Arch: "amd64", Binary: "kubectl-whoami"},
+				{Name: "kubectl-whoami_v0.0.2_linux_armv6.tar.gz", OS: "linux", Arch: "arm", Binary: "kubectl-whoami"},
+			},
+		},
+		{
+			name:          "dist/artifacts.json of an earlier release",
+			workdir:       "data/goreleaser-stale",
+			expectedError: `goreleaser artifacts in data/goreleaser-stale/dist are built for tag "v0.0.1", expected "v0.0.2"`,
+		},
+		{
+			name:          "binary archive format in .goreleaser.yml",
+			workdir:       "data/goreleaser-binary",
+			expectedError: "archive format binary for linux in data/goreleaser-binary/.goreleaser.yml is not supported. krew can only install plugins from tar.gz or zip archives",
+		},
+		{
+			name:          "no goreleaser config",
+			workdir:       "data/does-not-exist",
+			expectedError: `neither dist/artifacts.json nor .goreleaser.yml found in "data/does-not-exist"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			archives, err := getGoreleaserArchives(tc.workdir, "v0.0.2")
+			assert.Equal(t, tc.expectedArchives, archives)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestAddPlatformsFromGoreleaser(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	gock.New("https://github.com").
+		Get("/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz").
+		Reply(200).
+		BodyString("linux-amd64")
+
+	gock.New("https://github.com").
+		Get("/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_windows_amd64.zip").
+		Reply(200).
+		BodyString("windows-amd64")
+
+	expected := `- selector:
+      matchLabels:
+        os: linux
+        arch: amd64
+    uri: https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz
+    sha256: abb35c616421af72198ad7c2aeeef38516f08f6a7afb2a728cf0068a8a712ddc
+    files:
+    - from: "*"
+      to: "."
+    bin: kubectl-whoami
+  - selector:
+      matchLabels:
+        os: windows
+        arch: amd64
+    uri: https://github.com/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_windows_amd64.zip
+    sha256: b5c168db719e3a66397ce14e1907340ebdb78b910c98df897c665a1a74804190
+    files:
+    - from: "*"
+      to: "."
+    bin: kubectl-whoami.exe`
+
+	platforms, err := addPlatformsFromGoreleaser("https://github.com/rajatjindal/kubectl-whoami/releases/download/{{ .TagName }}", "v0.0.2", TemplateOptions{Workdir: "data/goreleaser-artifacts"})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, platforms)
+}
+
+func assertError(t *testing.T, expectedError string, err error) {
+	if expectedError == "" {
+		assert.Nil(t, err)
+	}
+
+	if expectedError != "" {
+		assert.NotNil(t, err)
+		if err != nil {
+			assert.Equal(t, expectedError, err.Error())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//GithubJWKSURL is the url of the keys github actions OIDC tokens are signed with
+const GithubJWKSURL = "https://token.actions.githubusercontent.com/.well-known/jwks"
+
+//KeySet provides the public keys, by key id, the tokens are signed with
+type KeySet interface {
+	Key(kid string) (*rsa.PublicKey, error)
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+//parseKeySet parses the RSA signing keys in the JWKS document. Other keys are skipped
+func parseKeySet(data []byte) (map[string]*rsa.PublicKey, error) {
+	set := jwks{}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("parsing jwks. error: %v", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding modulus of key %q. error: %v", key.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding exponent of key %q. error: %v", key.Kid, err)
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+//StaticKeySet is a fixed set of keys e.g. loaded from a file
+type StaticKeySet struct {
+	keys map[string]*rsa.PublicKey
+}
+
+//NewStaticKeySet returns the key set from the JWKS document
+func NewStaticKeySet(data []byte) (*StaticKeySet, error) {
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StaticKeySet{keys: keys}, nil
+}
+
+//LoadKeySet loads the key set from the JWKS file
+func LoadKeySet(file string) (*StaticKeySet, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStaticKeySet(data)
+}
+
+//Key returns the key with the id
+func (s *StaticKeySet) Key(kid string) (*rsa.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in jwks", kid)
+	}
+
+	return key, nil
+}
+
+//minRefreshInterval limits how often the keys are fetched again, when a token has an unknown key id
+const minRefreshInterval = time.Minute
+
+//RemoteKeySet fetches the keys from the JWKS url, and fetches them again when the keys are rotated
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+//NewRemoteKeySet returns the key set at the JWKS url
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url: url,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+//Key returns the key with the id, fetching the keys if not found
+func (s *RemoteKeySet) Key(kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	if s.keys == nil || now().Sub(s.fetchedAt) >= minRefreshInterval {
+		err := s.fetch()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in jwks at %s", kid, s.url)
+	}
+
+	return key, nil
+}
+
+//fetch fetches the keys. It must be called with the lock held
+func (s *RemoteKeySet) fetch() error {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching jwks from %s. expected status code %d got %d", s.url, http.StatusOK, resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
+	s.fetchedAt = now()
+	return nil
+}
@@ -0,0 +1,204 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//GithubIssuer is the issuer of github actions OIDC tokens
+const GithubIssuer = "https://token.actions.githubusercontent.com"
+
+//leeway is the allowed clock skew when checking the times in the token
+const leeway = time.Minute
+
+//now is overridden in tests
+var now = time.Now
+
+//Claims are the claims in github actions OIDC token used to authenticate the release
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+
+	//Repository is the repo the workflow ran in, as <owner>/<repo>
+	Repository      string `json:"repository"`
+	RepositoryOwner string `json:"repository_owner"`
+
+	//Ref is the git ref the workflow ran for e.g. refs/tags/v1.2.3
+	Ref string `json:"ref"`
+
+	//SHA is the commit the workflow ran for
+	SHA       string `json:"sha"`
+	Actor     string `json:"actor"`
+	EventName string `json:"event_name"`
+}
+
+//OwnerAndRepo returns the owner and repo from the repository claim
+func (c *Claims) OwnerAndRepo() (string, string, error) {
+	s := strings.Split(c.Repository, "/")
+	if len(s) != 2 || s[0] == "" || s[1] == "" {
+		return "", "", fmt.Errorf("repository claim is incorrect format. expected format <owner>/<repo>, found %q", c.Repository)
+	}
+
+	return s[0], s[1], nil
+}
+
+//Tag returns the tag from the ref claim, if the workflow ran for a tag
+func (c *Claims) Tag() (string, bool) {
+	if !strings.HasPrefix(c.Ref, "refs/tags/") {
+		return "", false
+	}
+
+	return strings.TrimPrefix(c.Ref, "refs/tags/"), true
+}
+
+//Branch returns the branch from the ref claim, if the workflow ran for a branch e.g. on workflow_dispatch
+func (c *Claims) Branch() (string, bool) {
+	if !strings.HasPrefix(c.Ref, "refs/heads/") {
+		return "", false
+	}
+
+	return strings.TrimPrefix(c.Ref, "refs/heads/"), true
+}
+
+//audience is either a string or a list of strings in the token
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var list []string
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return err
+	}
+
+	*a = list
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+
+	return false
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+//Verifier verifies OIDC tokens issued to github actions workflows
+type Verifier struct {
+	Issuer   string
+	Audience string
+	Keys     KeySet
+}
+
+//NewVerifier returns the verifier for github actions tokens with the audience, signed by the keys
+func NewVerifier(aud string, keys KeySet) *Verifier {
+	return &Verifier{
+		Issuer:   GithubIssuer,
+		Audience: aud,
+		Keys:     keys,
+	}
+}
+
+//Verify verifies the signature, issuer, audience and expiry of the token, and returns its claims
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a valid jwt")
+	}
+
+	h := header{}
+	err := decodeSegment(parts[0], &h)
+	if err != nil {
+		return nil, fmt.Errorf("decoding header of token. error: %v", err)
+	}
+
+	//only RS256 is accepted, so that tokens with alg none or signed using the public key as HMAC secret are rejected
+	if h.Alg != "RS256" {
+		return nil, fmt.Errorf("token signed using %q, expected RS256", h.Alg)
+	}
+
+	key, err := v.Keys.Key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature of token. error: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature of token")
+	}
+
+	claims := &Claims{}
+	err = decodeSegment(parts[1], claims)
+	if err != nil {
+		return nil, fmt.Errorf("decoding claims of token. error: %v", err)
+	}
+
+	err = v.validateClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) validateClaims(claims *Claims) error {
+	if claims.Issuer != v.Issuer {
+		return fmt.Errorf("token issued by %q, expected %q", claims.Issuer, v.Issuer)
+	}
+
+	if !claims.Audience.contains(v.Audience) {
+		return fmt.Errorf("token audience %q does not contain %q", []string(claims.Audience), v.Audience)
+	}
+
+	t := now()
+	if claims.ExpiresAt == 0 || t.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return fmt.Errorf("token is expired")
+	}
+
+	if claims.NotBefore != 0 && t.Before(time.Unix(claims.NotBefore, 0).Add(-leeway)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	if claims.Repository == "" {
+		return fmt.Errorf("repository claim not found in token")
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,230 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func jwksFor(t *testing.T, kid string, key *rsa.PrivateKey) []byte {
+	data, err := json.Marshal(jwks{Keys: []jwk{{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	assert.Nil(t, err)
+
+	return data
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.Nil(t, err)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	signingInput := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":              GithubIssuer,
+		"aud":              "krew-release-bot",
+		"exp":              time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC).Unix(),
+		"nbf":              time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		"repository":       "foo-bar/my-awesome-plugin",
+		"repository_owner": "foo-bar",
+		"ref":              "refs/tags/v0.0.2",
+		"actor":            "rajatjindal",
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now = func() time.Time { return time.Date(2020, 1, 1, 0, 30, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	keys, err := NewStaticKeySet(jwksFor(t, "key-1", key))
+	assert.Nil(t, err)
+
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		claims[name] = value
+		return claims
+	}
+
+	testcases := []struct {
+		name          string
+		token         func() string
+		expectedError string
+	}{
+		{
+			name:  "valid token",
+			token: func() string { return signToken(t, key, "key-1", validClaims()) },
+		},
+		{
+			name:  "audience is a list",
+			token: func() string { return signToken(t, key, "key-1", withClaim("aud", []string{"foo", "krew-release-bot"})) },
+		},
+		{
+			name:          "wrong audience",
+			token:         func() string { return signToken(t, key, "key-1", withClaim("aud", "sigstore")) },
+			expectedError: `token audience ["sigstore"] does not contain "krew-release-bot"`,
+		},
+		{
+			name:          "wrong issuer",
+			token:         func() string { return signToken(t, key, "key-1", withClaim("iss", "https://example.com")) },
+			expectedError: `token issued by "https://example.com", expected "https://token.actions.githubusercontent.com"`,
+		},
+		{
+			name:          "expired",
+			token:         func() string { return signToken(t, key, "key-1", withClaim("exp", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix())) },
+			expectedError: "token is expired",
+		},
+		{
+			name:          "not valid yet",
+			token:         func() string { return signToken(t, key, "key-1", withClaim("nbf", time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC).Unix())) },
+			expectedError: "token is not valid yet",
+		},
+		{
+			name:          "signed by another key",
+			token:         func() string { return signToken(t, otherKey, "key-1", validClaims()) },
+			expectedError: "invalid signature of token",
+		},
+		{
+			name:          "unknown key id",
+			token:         func() string { return signToken(t, key, "key-2", validClaims()) },
+			expectedError: `key "key-2" not found in jwks`,
+		},
+		{
+			name: "alg none",
+			token: func() string {
+				return encodeSegment(t, map[string]string{"alg": "none", "kid": "key-1"}) + "." + encodeSegment(t, validClaims()) + "."
+			},
+			expectedError: `token signed using "none", expected RS256`,
+		},
+		{
+			name: "HS256 using the public key as secret",
+			token: func() string {
+				signingInput := encodeSegment(t, map[string]string{"alg": "HS256", "kid": "key-1"}) + "." + encodeSegment(t, validClaims())
+				mac := hmac.New(sha256.New, key.N.Bytes())
+				mac.Write([]byte(signingInput))
+				return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+			},
+			expectedError: `token signed using "HS256", expected RS256`,
+		},
+		{
+			name:          "not a jwt",
+			token:         func() string { return "foo" },
+			expectedError: "token is not a valid jwt",
+		},
+	}
+
+	verifier := NewVerifier("krew-release-bot", keys)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tc.token())
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, "foo-bar/my-awesome-plugin", claims.Repository)
+			assert.Equal(t, "rajatjindal", claims.Actor)
+		})
+	}
+}
+
+func TestClaims(t *testing.T) {
+	claims := &Claims{Repository: "foo-bar/my-awesome-plugin", Ref: "refs/tags/kubectl-foo/v0.0.2"}
+
+	owner, repo, err := claims.OwnerAndRepo()
+	assert.Nil(t, err)
+	assert.Equal(t, "foo-bar", owner)
+	assert.Equal(t, "my-awesome-plugin", repo)
+
+	tag, ok := claims.Tag()
+	assert.True(t, ok)
+	assert.Equal(t, "kubectl-foo/v0.0.2", tag)
+
+	_, ok = (&Claims{Ref: "refs/heads/master"}).Tag()
+	assert.False(t, ok)
+
+	branch, ok := (&Claims{Ref: "refs/heads/master"}).Branch()
+	assert.True(t, ok)
+	assert.Equal(t, "master", branch)
+
+	_, ok = claims.Branch()
+	assert.False(t, ok)
+
+	_, _, err = (&Claims{Repository: "my-awesome-plugin"}).OwnerAndRepo()
+	assert.EqualError(t, err, `repository claim is incorrect format. expected format <owner>/<repo>, found "my-awesome-plugin"`)
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	defer gock.OffAll()
+	gock.DisableNetworking()
+
+	current := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	gock.New("https://token.actions.githubusercontent.com").
+		Get("/.well-known/jwks").
+		Reply(200).
+		BodyString(string(jwksFor(t, "key-1", key)))
+
+	keys := NewRemoteKeySet(GithubJWKSURL)
+
+	found, err := keys.Key("key-1")
+	assert.Nil(t, err)
+	assert.Equal(t, key.N, found.N)
+
+	//keys are not fetched again within the refresh interval
+	_, err = keys.Key("key-2")
+	assert.EqualError(t, err, fmt.Sprintf(`key "key-2" not found in jwks at %s`, GithubJWKSURL))
+
+	gock.New("https://token.actions.githubusercontent.com").
+		Get("/.well-known/jwks").
+		Reply(200).
+		BodyString(string(jwksFor(t, "key-2", rotatedKey)))
+
+	current = current.Add(minRefreshInterval)
+	found, err = keys.Key("key-2")
+	assert.Nil(t, err)
+	assert.Equal(t, rotatedKey.N, found.N)
+	assert.True(t, gock.IsDone())
+}
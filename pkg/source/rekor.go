@@ -0,0 +1,108 @@
+package source
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//rekorBundle is the transparency log entry of the signature, added by cosign sign-blob --bundle
+type rekorBundle struct {
+	SignedEntryTimestamp string       `json:"SignedEntryTimestamp"`
+	Payload              rekorPayload `json:"Payload"`
+}
+
+//rekorPayload is the entry signed by rekor in the SignedEntryTimestamp.
+//The fields are in the order of its canonical json, which is what rekor signs
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+//hashedRekord is the body of the transparency log entry for a signed blob
+type hashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+//verifyRekorBundle verifies that the signature of digest, with the signing certificate, was added
+//to the rekor transparency log, and returns the time it was added at
+func (v *SignatureVerifier) verifyRekorBundle(bundle *rekorBundle, digest, sig, certPEM []byte) (time.Time, error) {
+	if bundle == nil {
+		return time.Time{}, fmt.Errorf("no transparency log entry found, keyless signatures are verified only from bundles created using cosign sign-blob --bundle")
+	}
+
+	if len(v.RekorPublicKey) == 0 {
+		return time.Time{}, fmt.Errorf("rekor public key is required to verify keyless signatures")
+	}
+
+	key, err := parsePublicKey(v.RekorPublicKey)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid rekor public key. error: %v", err)
+	}
+
+	payload, err := json.Marshal(bundle.Payload)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	set, err := base64.StdEncoding.DecodeString(bundle.SignedEntryTimestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode signed entry timestamp. error: %v", err)
+	}
+
+	payloadDigest := sha256.Sum256(payload)
+	err = verifySignature(key, payloadDigest[:], set)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("verifying signed entry timestamp of transparency log entry failed. error: %v", err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode transparency log entry. error: %v", err)
+	}
+
+	entry := hashedRekord{}
+	err = json.Unmarshal(body, &entry)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse transparency log entry. error: %v", err)
+	}
+
+	if entry.Kind != "hashedrekord" || entry.Spec.Data.Hash.Algorithm != "sha256" {
+		return time.Time{}, fmt.Errorf("transparency log entry of kind %q with %q hash is not supported", entry.Kind, entry.Spec.Data.Hash.Algorithm)
+	}
+
+	if entry.Spec.Data.Hash.Value != hex.EncodeToString(digest) {
+		return time.Time{}, fmt.Errorf("transparency log entry is for sha256 %s, not of the archive", entry.Spec.Data.Hash.Value)
+	}
+
+	if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig) {
+		return time.Time{}, fmt.Errorf("transparency log entry is for another signature")
+	}
+
+	entryCertPEM, err := base64.StdEncoding.DecodeString(entry.Spec.Signature.PublicKey.Content)
+	if err != nil || !bytes.Equal(bytes.TrimSpace(entryCertPEM), bytes.TrimSpace(certPEM)) {
+		return time.Time{}, fmt.Errorf("transparency log entry is for another certificate")
+	}
+
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
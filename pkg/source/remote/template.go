@@ -0,0 +1,96 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/pkg/errors"
+	"github.com/rajatjindal/krew-release-bot/pkg/releaser"
+	"github.com/rajatjindal/krew-release-bot/pkg/source"
+)
+
+//RenderTemplate fetches the template file of the request from the tagged commit of the plugin repo, and renders it.
+//Only uris allowed by the policy are downloaded, as the template cannot be trusted.
+//Errors are *releaser.ReleaseError, with the code depending on whether the template or github is at fault
+func RenderTemplate(client *github.Client, request *source.ReleaseRequest, release *github.RepositoryRelease, repo *github.Repository, policy *releaser.Policy) (string, []byte, error) {
+	pluginName, manifest, err := renderTemplate(client, request, release, repo, policy)
+	if err != nil {
+		if _, ok := err.(*releaser.ReleaseError); !ok {
+			err = &releaser.ReleaseError{Code: source.ErrorCodeInvalidManifest, Err: err}
+		}
+
+		return "", nil, err
+	}
+
+	return pluginName, manifest, nil
+}
+
+func renderTemplate(client *github.Client, request *source.ReleaseRequest, release *github.RepositoryRelease, repo *github.Repository, policy *releaser.Policy) (string, []byte, error) {
+	opts := &github.RepositoryContentGetOptions{Ref: request.TagName}
+	file, _, _, err := client.Repositories.GetContents(context.TODO(), request.PluginOwner, request.PluginRepo, request.TemplateFile, opts)
+	if err != nil {
+		return "", nil, githubError(err, "fetching template %s at tag %q", request.TemplateFile, request.TagName)
+	}
+
+	if file == nil {
+		return "", nil, fmt.Errorf("template %s at tag %q is not a file", request.TemplateFile, request.TagName)
+	}
+
+	data, err := file.GetContent()
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "krew-release-bot-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	templateFile := filepath.Join(dir, filepath.Base(request.TemplateFile))
+	err = ioutil.WriteFile(templateFile, []byte(data), 0644)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sha, _, err := client.Repositories.GetCommitSHA1(context.TODO(), request.PluginOwner, request.PluginRepo, request.TagName, "")
+	if err != nil {
+		return "", nil, githubError(err, "getting commit for tag %q", request.TagName)
+	}
+
+	templateContext := source.NewTemplateContext(request, release, repo, sha)
+
+	//files are only read from the template's own directory, as the repo is not checked out
+	return source.ProcessTemplate(templateFile, templateContext, source.TemplateOptions{
+		Workdir: dir,
+		ValidateURI: func(uri string) error {
+			return policy.ValidateURI(request, uri)
+		},
+	})
+}
+
+//githubError is the error when calling github api fails. Things not found e.g. the template
+//are errors in the release, and others are upstream errors which may succeed if retried
+func githubError(err error, format string, args ...interface{}) error {
+	code := source.ErrorCodeUpstreamError
+	if e, ok := err.(*github.ErrorResponse); ok && e.Response != nil && e.Response.StatusCode == http.StatusNotFound {
+		code = source.ErrorCodeInvalidManifest
+	}
+
+	return &releaser.ReleaseError{Code: code, Err: errors.Wrapf(err, format, args...)}
+}
+
+//IsNotFound checks if rendering failed because the template, or the tag, is not found in the plugin repo
+func IsNotFound(err error) bool {
+	if releaseErr, ok := err.(*releaser.ReleaseError); ok {
+		err = releaseErr.Err
+	}
+
+	e, ok := errors.Cause(err).(*github.ErrorResponse)
+	return ok && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,83 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+//APIVersion is the version of the schema of responses from krew-release-bot webhook
+const APIVersion = "v1"
+
+//status of the release in the response
+const (
+	StatusSubmitted = "submitted"
+	StatusFailed    = "failed"
+
+	//StatusPending is the status of a job which is still running
+	StatusPending = "pending"
+
+	//StatusIgnored is the status when the event is not for a new release e.g. ping events
+	StatusIgnored = "ignored"
+)
+
+//ErrEventIgnored is returned by a Source when the request is valid, but is not for a new release
+var ErrEventIgnored = errors.New("event ignored")
+
+//error codes in the response. These are stable, and can be used by clients to handle the errors
+const (
+	ErrorCodeInvalidRequest        = "invalid_request"
+	ErrorCodeUnauthorized          = "unauthorized"
+	ErrorCodePluginNotFound        = "plugin_not_found"
+	ErrorCodeOwnershipMismatch     = "ownership_mismatch"
+	ErrorCodeProtectedFieldChanged = "protected_field_changed"
+	ErrorCodeInvalidManifest       = "invalid_manifest"
+	ErrorCodeURINotAllowed         = "uri_not_allowed"
+	ErrorCodeTagNotAtCommit        = "tag_not_at_commit"
+	ErrorCodeVersionMismatch       = "version_mismatch"
+	ErrorCodeInvalidAssets         = "invalid_assets"
+	ErrorCodeJobNotFound           = "job_not_found"
+	ErrorCodeUpstreamError         = "upstream_error"
+	ErrorCodeInternalError         = "internal_error"
+)
+
+//ReleaseResponse is the response from krew-release-bot webhook
+type ReleaseResponse struct {
+	APIVersion string         `json:"apiVersion"`
+	Status     string         `json:"status"`
+	PRURL      string         `json:"pr_url,omitempty"`
+	JobID      string         `json:"job_id,omitempty"`
+	Error      *ResponseError `json:"error,omitempty"`
+}
+
+//ResponseError is the error in the response, when the release failed
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	//Retryable is true when the same request may succeed if sent again later
+	Retryable bool `json:"retryable"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (code: %s)", e.Message, e.Code)
+}
+
+//PreferHeader set to PreferRespondAsync submits the release in the background. The webhook then
+//responds with the id of the job, which can be polled at /jobs/<id>
+const (
+	PreferHeader       = "Prefer"
+	PreferRespondAsync = "respond-async"
+)
+
+//IdempotencyKeyHeader is the header with the idempotency key of the release request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+//IdempotencyKey returns the key identifying the release request, derived from the plugin repo,
+//tag and the rendered manifest. Requests with the same key open only one PR
+func IdempotencyKey(request *ReleaseRequest) string {
+	manifest := sha256.Sum256(request.ProcessedTemplate)
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%x", request.PluginOwner, request.PluginRepo, request.TagName, manifest)))
+	return hex.EncodeToString(key[:])
+}
@@ -51,13 +51,20 @@ func downloadFile(uri string) (string, error) {
 	return DownloadFileWithName(uri, fmt.Sprintf("%d", time.Now().Unix()))
 }
 
-func getSha256ForAsset(uri string) (string, error) {
+func getSha256ForAsset(uri string, verifier *SignatureVerifier) (string, error) {
 	file, err := downloadFile(uri)
 	if err != nil {
 		return "", err
 	}
 
 	defer os.Remove(file)
+	if verifier != nil {
+		err = verifier.Verify(uri, file)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	sha256, err := getSha256(file)
 	if err != nil {
 		return "", err
@@ -0,0 +1,379 @@
+package source
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//maxSignatureAssetSize is the max size of .sig, .pem and .bundle assets
+const maxSignatureAssetSize = 1 << 20
+
+//oids of the extensions fulcio adds to signing certificates for the oidc issuer
+var (
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+//SignatureVerifier verifies cosign signatures of the release archives,
+//before their sha256 is added to the plugin manifest.
+//
+//Signatures are looked up next to each archive as <archive>.bundle,
+//or <archive>.sig with an optional <archive>.pem certificate
+type SignatureVerifier struct {
+	//PublicKey is the PEM encoded public key the archives are signed with
+	PublicKey []byte
+
+	//CertificateIdentity and CertificateOIDCIssuer are the expected email or uri,
+	//and oidc issuer of the signing certificate, for keyless signing
+	CertificateIdentity   string
+	CertificateOIDCIssuer string
+
+	//CertificateRoots are the PEM encoded CA certificates the signing certificate chains up to
+	CertificateRoots []byte
+
+	//RekorPublicKey is the PEM encoded public key of the rekor transparency log, which keyless
+	//signatures must be logged in while the signing certificate was valid
+	RekorPublicKey []byte
+
+	//Required fails the release when no signature is found for an archive
+	Required bool
+
+	results []SignatureResult
+}
+
+//SignatureResult is the result of verifying the signature of one archive
+type SignatureResult struct {
+	URI      string
+	Verified bool
+	Details  string
+}
+
+//cosignBundle is the bundle written by cosign sign-blob --bundle
+type cosignBundle struct {
+	Base64Signature string       `json:"base64Signature"`
+	Cert            string       `json:"cert"`
+	RekorBundle     *rekorBundle `json:"rekorBundle,omitempty"`
+}
+
+//signature is the signature of an archive, with the certificate and transparency log entry if any
+type signature struct {
+	sig     []byte
+	certPEM []byte
+	rekor   *rekorBundle
+}
+
+//Results returns the results of all verifications done so far
+func (v *SignatureVerifier) Results() []SignatureResult {
+	return v.results
+}
+
+//Summary returns the results as markdown, to be added to the job summary
+func (v *SignatureVerifier) Summary() string {
+	lines := []string{}
+	for _, result := range v.results {
+		status := ":warning: not verified"
+		if result.Verified {
+			status = ":white_check_mark: verified"
+		}
+
+		lines = append(lines, fmt.Sprintf("- %s: %s (%s)", result.URI, status, result.Details))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+//Verify verifies the signature of the archive downloaded from uri to file
+func (v *SignatureVerifier) Verify(uri, file string) error {
+	sig, err := v.fetchSignature(uri)
+	if err != nil {
+		return err
+	}
+
+	if sig == nil {
+		if v.Required {
+			return fmt.Errorf("no signature found for %s, expected %s.sig or %s.bundle", uri, uri, uri)
+		}
+
+		logrus.Warnf("no signature found for %s", uri)
+		v.results = append(v.results, SignatureResult{URI: uri, Details: "no signature found"})
+		return nil
+	}
+
+	digest, err := fileDigest(file)
+	if err != nil {
+		return err
+	}
+
+	details, err := v.verifyDigest(digest, sig)
+	if err != nil {
+		return fmt.Errorf("verifying signature of %s failed. error: %v", uri, err)
+	}
+
+	logrus.Infof("signature of %s %s", uri, details)
+	v.results = append(v.results, SignatureResult{URI: uri, Verified: true, Details: details})
+	return nil
+}
+
+//fetchSignature returns the signature, and certificate and transparency log entry if any, for the archive at uri.
+//It returns nil if the archive is not signed
+func (v *SignatureVerifier) fetchSignature(uri string) (*signature, error) {
+	data, found, err := fetchSignatureAsset(uri + ".bundle")
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		bundle := cosignBundle{}
+		err = json.Unmarshal(data, &bundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s.bundle. error: %v", uri, err)
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(bundle.Base64Signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature in %s.bundle. error: %v", uri, err)
+		}
+
+		return &signature{sig: sig, certPEM: decodePEM([]byte(bundle.Cert)), rekor: bundle.RekorBundle}, nil
+	}
+
+	data, found, err = fetchSignatureAsset(uri + ".sig")
+	if err != nil || !found {
+		return nil, err
+	}
+
+	//cosign writes the signature base64 encoded
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		sig = data
+	}
+
+	certPEM, _, err := fetchSignatureAsset(uri + ".pem")
+	if err != nil {
+		return nil, err
+	}
+
+	return &signature{sig: sig, certPEM: decodePEM(certPEM)}, nil
+}
+
+func (v *SignatureVerifier) verifyDigest(digest []byte, s *signature) (string, error) {
+	if len(v.PublicKey) > 0 {
+		key, err := parsePublicKey(v.PublicKey)
+		if err != nil {
+			return "", err
+		}
+
+		err = verifySignature(key, digest, s.sig)
+		if err != nil {
+			return "", err
+		}
+
+		return "verified with public key", nil
+	}
+
+	if v.CertificateIdentity == "" {
+		return "", fmt.Errorf("neither public key nor certificate identity is configured")
+	}
+
+	if s.certPEM == nil {
+		return "", fmt.Errorf("no certificate found for keyless signature")
+	}
+
+	cert, err := parseCertificate(s.certPEM)
+	if err != nil {
+		return "", err
+	}
+
+	integratedTime, err := v.verifyRekorBundle(s.rekor, digest, s.sig, s.certPEM)
+	if err != nil {
+		return "", err
+	}
+
+	err = v.verifyCertificate(cert, integratedTime)
+	if err != nil {
+		return "", err
+	}
+
+	err = verifySignature(cert.PublicKey, digest, s.sig)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("verified with certificate for %s issued by %s, logged in transparency log at index %d", v.CertificateIdentity, certificateIssuer(cert), s.rekor.Payload.LogIndex), nil
+}
+
+//verifyCertificate verifies the chain, identity and issuer of the signing certificate.
+//Signing certificates are short lived, so the chain is verified at the time the signature was
+//added to the transparency log, which must be while the certificate was valid
+func (v *SignatureVerifier) verifyCertificate(cert *x509.Certificate, signedAt time.Time) error {
+	if len(v.CertificateRoots) == 0 {
+		return fmt.Errorf("certificate roots are required to verify keyless signatures")
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(v.CertificateRoots) {
+		return fmt.Errorf("no certificates found in certificate roots")
+	}
+
+	if signedAt.Before(cert.NotBefore) || signedAt.After(cert.NotAfter) {
+		return fmt.Errorf("signature was logged in transparency log at %s, when the signing certificate was not valid", signedAt.UTC().Format(time.RFC3339))
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: signedAt,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return err
+	}
+
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+
+	if !Contains(identities, v.CertificateIdentity) {
+		return fmt.Errorf("certificate identity %v does not match expected identity %s", identities, v.CertificateIdentity)
+	}
+
+	if v.CertificateOIDCIssuer != "" && certificateIssuer(cert) != v.CertificateOIDCIssuer {
+		return fmt.Errorf("certificate oidc issuer %q does not match expected issuer %s", certificateIssuer(cert), v.CertificateOIDCIssuer)
+	}
+
+	return nil
+}
+
+//fetchSignatureAsset downloads a signature asset. It returns false if the asset does not exist
+func fetchSignatureAsset(uri string) ([]byte, bool, error) {
+	resp, err := http.Get(uri)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("downloading file %s failed. status code: %d, expected: %d", uri, resp.StatusCode, http.StatusOK)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSignatureAssetSize))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+//decodePEM returns the PEM data, decoding it first if cosign wrote it base64 encoded
+func decodePEM(data []byte) []byte {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(trimmed, "-----BEGIN") {
+		return []byte(trimmed)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(trimmed)
+	if err != nil {
+		return []byte(trimmed)
+	}
+
+	return decoded
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("certificate is not PEM encoded")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuerV1) {
+			return string(ext.Value)
+		}
+
+		if ext.Id.Equal(oidIssuerV2) {
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		}
+	}
+
+	return ""
+}
+
+func verifySignature(key crypto.PublicKey, digest, sig []byte) error {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		var esig struct {
+			R, S *big.Int
+		}
+
+		_, err := asn1.Unmarshal(sig, &esig)
+		if err != nil {
+			return fmt.Errorf("invalid ecdsa signature. error: %v", err)
+		}
+
+		if !ecdsa.Verify(k, digest, esig.R, esig.S) {
+			return fmt.Errorf("invalid signature")
+		}
+
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig)
+	}
+
+	return fmt.Errorf("unsupported public key type %T", key)
+}
+
+func fileDigest(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
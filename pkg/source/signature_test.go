@@ -0,0 +1,299 @@
+package source
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const archivePath = "/rajatjindal/kubectl-whoami/releases/download/v0.0.2/kubectl-whoami_v0.0.2_linux_amd64.tar.gz"
+
+var archiveContent = []byte("not really an archive")
+
+func TestSignatureVerifier(t *testing.T) {
+	key := newKey(t)
+	otherKey := newKey(t)
+	caKey := newKey(t)
+	rekorKey := newKey(t)
+	signedAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	ca, caPEM := newCertificate(t, caKey, caKey, nil, "")
+	_, certPEM := newCertificate(t, caKey, key, ca, "foo@example.com")
+
+	testcases := []struct {
+		name             string
+		verifier         *SignatureVerifier
+		bundle           []byte
+		sig              []byte
+		expectedError    string
+		expectedVerified bool
+		expectedDetails  string
+	}{
+		{
+			name:             "signed with public key",
+			verifier:         &SignatureVerifier{PublicKey: publicKeyPEM(t, key)},
+			sig:              []byte(base64.StdEncoding.EncodeToString(sign(t, key, archiveContent))),
+			expectedVerified: true,
+			expectedDetails:  "verified with public key",
+		},
+		{
+			name:          "signed with another key",
+			verifier:      &SignatureVerifier{PublicKey: publicKeyPEM(t, key)},
+			sig:           []byte(base64.StdEncoding.EncodeToString(sign(t, otherKey, archiveContent))),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: invalid signature",
+		},
+		{
+			name:            "signature not found and not required",
+			verifier:        &SignatureVerifier{PublicKey: publicKeyPEM(t, key)},
+			expectedDetails: "no signature found",
+		},
+		{
+			name:          "signature not found and required",
+			verifier:      &SignatureVerifier{PublicKey: publicKeyPEM(t, key), Required: true},
+			expectedError: "no signature found for https://github.com" + archivePath + ", expected https://github.com" + archivePath + ".sig or https://github.com" + archivePath + ".bundle",
+		},
+		{
+			name: "keyless signature in bundle",
+			verifier: &SignatureVerifier{
+				CertificateIdentity:   "foo@example.com",
+				CertificateOIDCIssuer: "https://accounts.example.com",
+				CertificateRoots:      caPEM,
+				RekorPublicKey:        publicKeyPEM(t, rekorKey),
+			},
+			bundle:           newSignedBundle(t, rekorKey, key, certPEM, time.Now()),
+			expectedVerified: true,
+			expectedDetails:  "verified with certificate for foo@example.com issued by https://accounts.example.com, logged in transparency log at index 42",
+		},
+		{
+			name: "keyless signature for another identity",
+			verifier: &SignatureVerifier{
+				CertificateIdentity: "bar@example.com",
+				CertificateRoots:    caPEM,
+				RekorPublicKey:      publicKeyPEM(t, rekorKey),
+			},
+			bundle:        newSignedBundle(t, rekorKey, key, certPEM, time.Now()),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: certificate identity [foo@example.com] does not match expected identity bar@example.com",
+		},
+		{
+			name: "keyless signature without roots",
+			verifier: &SignatureVerifier{
+				CertificateIdentity: "foo@example.com",
+				RekorPublicKey:      publicKeyPEM(t, rekorKey),
+			},
+			bundle:        newSignedBundle(t, rekorKey, key, certPEM, time.Now()),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: certificate roots are required to verify keyless signatures",
+		},
+		{
+			name: "keyless signature without transparency log entry",
+			verifier: &SignatureVerifier{
+				CertificateIdentity: "foo@example.com",
+				CertificateRoots:    caPEM,
+				RekorPublicKey:      publicKeyPEM(t, rekorKey),
+			},
+			bundle:        newBundle(t, sign(t, key, archiveContent), certPEM, nil),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: no transparency log entry found, keyless signatures are verified only from bundles created using cosign sign-blob --bundle",
+		},
+		{
+			name: "keyless signature without rekor public key",
+			verifier: &SignatureVerifier{
+				CertificateIdentity: "foo@example.com",
+				CertificateRoots:    caPEM,
+			},
+			bundle:        newSignedBundle(t, rekorKey, key, certPEM, time.Now()),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: rekor public key is required to verify keyless signatures",
+		},
+		{
+			name: "keyless signature with transparency log entry signed by another key",
+			verifier: &SignatureVerifier{
+				CertificateIdentity: "foo@example.com",
+				CertificateRoots:    caPEM,
+				RekorPublicKey:      publicKeyPEM(t, rekorKey),
+			},
+			bundle:        newSignedBundle(t, otherKey, key, certPEM, time.Now()),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: verifying signed entry timestamp of transparency log entry failed. error: invalid signature",
+		},
+		{
+			name: "keyless signature with transparency log entry of another signature",
+			verifier: &SignatureVerifier{
+				CertificateIdentity: "foo@example.com",
+				CertificateRoots:    caPEM,
+				RekorPublicKey:      publicKeyPEM(t, rekorKey),
+			},
+			bundle:        newBundle(t, sign(t, key, archiveContent), certPEM, newRekorBundle(t, rekorKey, sign(t, key, archiveContent), certPEM, time.Now())),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: transparency log entry is for another signature",
+		},
+		{
+			name: "keyless signature logged after the certificate expired",
+			verifier: &SignatureVerifier{
+				CertificateIdentity: "foo@example.com",
+				CertificateRoots:    caPEM,
+				RekorPublicKey:      publicKeyPEM(t, rekorKey),
+			},
+			bundle:        newSignedBundle(t, rekorKey, key, certPEM, signedAt),
+			expectedError: "verifying signature of https://github.com" + archivePath + " failed. error: signature was logged in transparency log at " + signedAt.UTC().Format(time.RFC3339) + ", when the signing certificate was not valid",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer gock.OffAll()
+			gock.DisableNetworking()
+
+			gock.New("https://github.com").
+				Get(archivePath).
+				Reply(200).
+				BodyString(string(archiveContent))
+
+			if tc.bundle != nil {
+				gock.New("https://github.com").
+					Get(archivePath + ".bundle").
+					Reply(200).
+					BodyString(string(tc.bundle))
+			} else {
+				gock.New("https://github.com").
+					Get(archivePath + ".bundle").
+					Reply(404)
+			}
+
+			if tc.bundle == nil && tc.sig != nil {
+				gock.New("https://github.com").
+					Get(archivePath + ".sig").
+					Reply(200).
+					BodyString(string(tc.sig))
+
+				gock.New("https://github.com").
+					Get(archivePath + ".pem").
+					Reply(404)
+			} else if tc.bundle == nil {
+				gock.New("https://github.com").
+					Get(archivePath + ".sig").
+					Reply(404)
+			}
+
+			sha, err := getSha256ForAsset("https://github.com"+archivePath, tc.verifier)
+			assertError(t, tc.expectedError, err)
+			if tc.expectedError != "" {
+				return
+			}
+
+			expectedSha := sha256.Sum256(archiveContent)
+			assert.Equal(t, hex.EncodeToString(expectedSha[:]), sha)
+			assert.Equal(t, []SignatureResult{
+				{
+					URI:      "https://github.com" + archivePath,
+					Verified: tc.expectedVerified,
+					Details:  tc.expectedDetails,
+				},
+			}, tc.verifier.Results())
+		})
+	}
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	return key
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	assert.Nil(t, err)
+
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	assert.Nil(t, err)
+	return sig
+}
+
+func publicKeyPEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+//newCertificate creates a CA certificate if parent is nil, otherwise a signing certificate for email
+func newCertificate(t *testing.T, signer, key *ecdsa.PrivateKey, parent *x509.Certificate, email string) (*x509.Certificate, []byte) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(10 * time.Minute),
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent = template
+	} else {
+		template.EmailAddresses = []string{email}
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+		template.ExtraExtensions = []pkix.Extension{{Id: oidIssuerV1, Value: []byte("https://accounts.example.com")}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	assert.Nil(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func newBundle(t *testing.T, sig, certPEM []byte, rekor *rekorBundle) []byte {
+	bundle, err := json.Marshal(cosignBundle{
+		Base64Signature: base64.StdEncoding.EncodeToString(sig),
+		Cert:            base64.StdEncoding.EncodeToString(certPEM),
+		RekorBundle:     rekor,
+	})
+	assert.Nil(t, err)
+	return bundle
+}
+
+//newSignedBundle signs the archive with key, and logs the signature in the transparency log signed with rekorKey
+func newSignedBundle(t *testing.T, rekorKey, key *ecdsa.PrivateKey, certPEM []byte, integratedTime time.Time) []byte {
+	sig := sign(t, key, archiveContent)
+	return newBundle(t, sig, certPEM, newRekorBundle(t, rekorKey, sig, certPEM, integratedTime))
+}
+
+//newRekorBundle creates the transparency log entry of sig for the archive, signed with rekorKey
+func newRekorBundle(t *testing.T, rekorKey *ecdsa.PrivateKey, sig, certPEM []byte, integratedTime time.Time) *rekorBundle {
+	entry := hashedRekord{Kind: "hashedrekord"}
+	digest := sha256.Sum256(archiveContent)
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(digest[:])
+	entry.Spec.Signature.Content = base64.StdEncoding.EncodeToString(sig)
+	entry.Spec.Signature.PublicKey.Content = base64.StdEncoding.EncodeToString(certPEM)
+
+	body, err := json.Marshal(entry)
+	assert.Nil(t, err)
+
+	payload := rekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: integratedTime.Unix(),
+		LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+		LogIndex:       42,
+	}
+
+	data, err := json.Marshal(payload)
+	assert.Nil(t, err)
+
+	return &rekorBundle{
+		SignedEntryTimestamp: base64.StdEncoding.EncodeToString(sign(t, rekorKey, data)),
+		Payload:              payload,
+	}
+}
@@ -1,7 +1,15 @@
 package source
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
 //Source is a release source interface
@@ -18,4 +26,185 @@ type ReleaseRequest struct {
 	PluginReleaseActor string `json:"pluginReleaseActor"`
 	TemplateFile       string `json:"templateFile"`
 	ProcessedTemplate  []byte `json:"processedTemplate"`
+
+	//Version is the expected spec.version of the plugin, when it cannot be derived from TagName
+	Version string `json:"version,omitempty"`
+
+	//SignatureVerification is the markdown summary of verifying signatures of the archives. It is added
+	//to the PR only in standalone mode, where the PR is opened by the action which verified the signatures
+	SignatureVerification string `json:"-"`
+}
+
+//TemplateContext is the data available when processing the .krew.yaml template
+type TemplateContext struct {
+	*ReleaseRequest
+
+	ReleaseName     string
+	ReleaseNotes    string
+	PublishedAt     time.Time
+	CommitSHA       string
+	RepoDescription string
+	License         string
+	SemVer          SemVer
+}
+
+//NewTemplateContext returns the context for the release of the repo, at commitSHA of the released tag
+func NewTemplateContext(request *ReleaseRequest, release *github.RepositoryRelease, repo *github.Repository, commitSHA string) *TemplateContext {
+	semver, err := ReleaseSemVer(request)
+	if err != nil {
+		logrus.Warnf("version of tag %q is not a valid semver, .SemVer will be empty. error: %v", request.TagName, err)
+	}
+
+	return &TemplateContext{
+		ReleaseRequest:  request,
+		ReleaseName:     release.GetName(),
+		ReleaseNotes:    release.GetBody(),
+		PublishedAt:     release.GetPublishedAt().Time,
+		CommitSHA:       commitSHA,
+		RepoDescription: repo.GetDescription(),
+		License:         repo.GetLicense().GetSPDXID(),
+		SemVer:          semver,
+	}
+}
+
+//SemVer is the semver parsed version of the release tag
+type SemVer struct {
+	Major         uint
+	Minor         uint
+	Patch         uint
+	Prerelease    string
+	BuildMetadata string
+}
+
+//ParseSemVer parses the tag as semver, allowing a leading v
+func ParseSemVer(tag string) (SemVer, error) {
+	v, err := version.ParseSemantic(tag)
+	if err != nil {
+		return SemVer{}, err
+	}
+
+	return SemVer{
+		Major:         v.Major(),
+		Minor:         v.Minor(),
+		Patch:         v.Patch(),
+		Prerelease:    v.PreRelease(),
+		BuildMetadata: v.BuildMetadata(),
+	}, nil
+}
+
+//ReleaseSemVer parses the version being released as semver. It is the version mapped from the tag
+//using the tag prefix or pattern if set, or else the semver at the end of the tag
+func ReleaseSemVer(request *ReleaseRequest) (SemVer, error) {
+	v := request.Version
+	if v == "" {
+		var err error
+		v, err = VersionFromTag(request.TagName)
+		if err != nil {
+			return SemVer{}, err
+		}
+	}
+
+	return ParseSemVer(v)
+}
+
+//VersionForTag returns the krew version for the tag, i.e. the tag without tagPrefix,
+//with a v prefix e.g. release-1.2.3 with tagPrefix release- is v1.2.3
+func VersionForTag(tag, tagPrefix string) (string, error) {
+	v := strings.TrimPrefix(tag, tagPrefix)
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+
+	_, err := version.ParseSemantic(v)
+	if err != nil {
+		return "", fmt.Errorf("version %q for tag %q is not a valid semver. error: %v", v, tag, err)
+	}
+
+	return v, nil
+}
+
+//tagVersion matches the semver at the end of a tag, after a / - _ or @ separator
+var tagVersion = regexp.MustCompile(`(?:^|[/_@-])(v?\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?)$`)
+
+//VersionFromTag returns the krew version for the semver at the end of the tag, with a v prefix
+//e.g. v1.2.3 for tags like v1.2.3, release-1.2.3 and kubectl-foo/v1.2.3. It does not depend on the
+//tag prefix or pattern the tag was matched with, so it can be used to validate the version on the server
+func VersionFromTag(tag string) (string, error) {
+	matches := tagVersion.FindStringSubmatch(tag)
+	if matches == nil {
+		return "", fmt.Errorf("tag %q does not end with a semver version", tag)
+	}
+
+	return VersionForTag(matches[1], "")
+}
+
+//TagVersion returns the krew version of the tag, matched using tagPattern if set,
+//otherwise the semver at the end of the tag
+func TagVersion(tag, tagPattern string) (string, error) {
+	if tagPattern == "" {
+		return VersionFromTag(tag)
+	}
+
+	match, err := MatchTag(tagPattern, tag)
+	if err != nil {
+		return "", err
+	}
+
+	return match.Version, nil
+}
+
+//ValidateVersion validates that spec.version of the plugin matches the version of the released tag.
+//It is used by both the action and the server, so that a release passing it in the action is not
+//rejected by the server. The version is derived from the tag, as the version in the request can be
+//set by any client
+func ValidateVersion(specVersion, tag, tagPattern string) error {
+	expected, err := TagVersion(tag, tagPattern)
+	if err != nil {
+		return err
+	}
+
+	if specVersion != expected {
+		return fmt.Errorf("spec.version %q in the plugin manifest does not match version %q of the released tag %q", specVersion, expected, tag)
+	}
+
+	return nil
+}
+
+//TagMatch is the plugin and version matched from a tag using a tag pattern
+type TagMatch struct {
+	Plugin  string
+	Version string
+}
+
+//MatchTag matches the tag against pattern, a regular expression with optional named captures
+//plugin and version e.g. ^kubectl-(?P<plugin>[a-z-]+)/(?P<version>.+)$ for tags like kubectl-foo/v1.2.3.
+//The version is normalized to have a v prefix
+func MatchTag(pattern, tag string) (TagMatch, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return TagMatch{}, fmt.Errorf("invalid tag pattern %q. error: %v", pattern, err)
+	}
+
+	matches := re.FindStringSubmatch(tag)
+	if matches == nil {
+		return TagMatch{}, fmt.Errorf("tag %q does not match tag pattern %q", tag, pattern)
+	}
+
+	match := TagMatch{}
+	version := tag
+	for i, name := range re.SubexpNames() {
+		switch name {
+		case "plugin":
+			match.Plugin = matches[i]
+		case "version":
+			version = matches[i]
+		}
+	}
+
+	match.Version, err = VersionForTag(version, "")
+	if err != nil {
+		return TagMatch{}, err
+	}
+
+	return match, nil
 }
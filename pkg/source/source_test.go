@@ -0,0 +1,258 @@
+package source
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+func TestParseSemVer(t *testing.T) {
+	testcases := []struct {
+		name           string
+		tag            string
+		expectedSemVer SemVer
+		expectedError  string
+	}{
+		{
+			name:           "tag with v prefix",
+			tag:            "v1.2.3",
+			expectedSemVer: SemVer{Major: 1, Minor: 2, Patch: 3},
+		},
+		{
+			name:           "tag without v prefix",
+			tag:            "0.10.0",
+			expectedSemVer: SemVer{Major: 0, Minor: 10, Patch: 0},
+		},
+		{
+			name:           "tag with prerelease and build metadata",
+			tag:            "v2.0.0-rc.1+build.5",
+			expectedSemVer: SemVer{Major: 2, Minor: 0, Patch: 0, Prerelease: "rc.1", BuildMetadata: "build.5"},
+		},
+		{
+			name:          "tag is not semver",
+			tag:           "release-2020",
+			expectedError: `could not parse "release-2020" as version`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			semver, err := ParseSemVer(tc.tag)
+			assert.Equal(t, tc.expectedSemVer, semver)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestVersionForTag(t *testing.T) {
+	testcases := []struct {
+		name            string
+		tag             string
+		tagPrefix       string
+		expectedVersion string
+		expectedError   string
+	}{
+		{
+			name:            "tag with v prefix",
+			tag:             "v1.2.3",
+			expectedVersion: "v1.2.3",
+		},
+		{
+			name:            "tag without v prefix",
+			tag:             "1.2.3",
+			expectedVersion: "v1.2.3",
+		},
+		{
+			name:            "tag with custom prefix",
+			tag:             "release-1.2.3",
+			tagPrefix:       "release-",
+			expectedVersion: "v1.2.3",
+		},
+		{
+			name:          "tag with unknown prefix",
+			tag:           "release-1.2.3",
+			expectedError: `version "vrelease-1.2.3" for tag "release-1.2.3" is not a valid semver. error: could not parse "vrelease-1.2.3" as version`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := VersionForTag(tc.tag, tc.tagPrefix)
+			assert.Equal(t, tc.expectedVersion, v)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestReleaseSemVer(t *testing.T) {
+	testcases := []struct {
+		name           string
+		request        *ReleaseRequest
+		expectedSemVer SemVer
+		expectedError  string
+	}{
+		{
+			name:           "tag is semver",
+			request:        &ReleaseRequest{TagName: "v1.2.3"},
+			expectedSemVer: SemVer{Major: 1, Minor: 2, Patch: 3},
+		},
+		{
+			name:           "version mapped from monorepo tag",
+			request:        &ReleaseRequest{TagName: "kubectl-foo/v1.2.3", Version: "v1.2.3"},
+			expectedSemVer: SemVer{Major: 1, Minor: 2, Patch: 3},
+		},
+		{
+			name:           "tag with prefix and no mapped version",
+			request:        &ReleaseRequest{TagName: "release-1.2.3-rc.1"},
+			expectedSemVer: SemVer{Major: 1, Minor: 2, Patch: 3, Prerelease: "rc.1"},
+		},
+		{
+			name:          "tag without version",
+			request:       &ReleaseRequest{TagName: "latest"},
+			expectedError: `tag "latest" does not end with a semver version`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			semver, err := ReleaseSemVer(tc.request)
+			assert.Equal(t, tc.expectedSemVer, semver)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestNewTemplateContext(t *testing.T) {
+	request := &ReleaseRequest{TagName: "v1.2.3", PluginOwner: "foo-bar", PluginRepo: "kubectl-whoami"}
+	publishedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	release := &github.RepositoryRelease{
+		Name:        github.String("whoami v1.2.3"),
+		Body:        github.String("bug fixes"),
+		PublishedAt: &github.Timestamp{Time: publishedAt},
+	}
+	repo := &github.Repository{
+		Description: github.String("whoami plugin"),
+		License:     &github.License{SPDXID: github.String("Apache-2.0")},
+	}
+
+	assert.Equal(t, &TemplateContext{
+		ReleaseRequest:  request,
+		ReleaseName:     "whoami v1.2.3",
+		ReleaseNotes:    "bug fixes",
+		PublishedAt:     publishedAt,
+		CommitSHA:       "8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e",
+		RepoDescription: "whoami plugin",
+		License:         "Apache-2.0",
+		SemVer:          SemVer{Major: 1, Minor: 2, Patch: 3},
+	}, NewTemplateContext(request, release, repo, "8f8c5a1c3b9e0d5f1a7e2b4c6d8e0f1a2b3c4d5e"))
+}
+
+func TestVersionFromTag(t *testing.T) {
+	testcases := []struct {
+		tag             string
+		expectedVersion string
+		expectedError   string
+	}{
+		{tag: "v1.2.3", expectedVersion: "v1.2.3"},
+		{tag: "1.2.3", expectedVersion: "v1.2.3"},
+		{tag: "release-1.2.3", expectedVersion: "v1.2.3"},
+		{tag: "kubectl-foo/v1.2.3", expectedVersion: "v1.2.3"},
+		{tag: "kubectl-foo/v1.2.3-rc.1", expectedVersion: "v1.2.3-rc.1"},
+		{tag: "foo@1.2.3+build.5", expectedVersion: "v1.2.3+build.5"},
+		{tag: "release-2020", expectedError: `tag "release-2020" does not end with a semver version`},
+		{tag: "kubectl-foo1.2.3", expectedError: `tag "kubectl-foo1.2.3" does not end with a semver version`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.tag, func(t *testing.T) {
+			version, err := VersionFromTag(tc.tag)
+			assert.Equal(t, tc.expectedVersion, version)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
+
+//versionTestCase is a case of validating the version of a released tag, shared by the tests of the action and the server
+type versionTestCase struct {
+	Name          string `json:"name"`
+	Tag           string `json:"tag"`
+	TagPattern    string `json:"tagPattern"`
+	Version       string `json:"version"`
+	SpecVersion   string `json:"specVersion"`
+	ExpectedError string `json:"expectedError"`
+}
+
+func TestValidateVersion(t *testing.T) {
+	data, err := ioutil.ReadFile("data/versions/versions.yaml")
+	assert.Nil(t, err)
+
+	testcases := []versionTestCase{}
+	err = yaml.UnmarshalStrict(data, &testcases)
+	assert.Nil(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := ValidateVersion(tc.SpecVersion, tc.Tag, tc.TagPattern)
+			assertError(t, tc.ExpectedError, err)
+		})
+	}
+}
+
+func TestMatchTag(t *testing.T) {
+	testcases := []struct {
+		name          string
+		pattern       string
+		tag           string
+		expectedMatch TagMatch
+		expectedError string
+	}{
+		{
+			name:          "monorepo tag",
+			pattern:       `^kubectl-(?P<plugin>[a-z-]+)/(?P<version>.+)$`,
+			tag:           "kubectl-foo/v1.2.3",
+			expectedMatch: TagMatch{Plugin: "foo", Version: "v1.2.3"},
+		},
+		{
+			name:          "version without v prefix",
+			pattern:       `^(?P<plugin>[a-z-]+)-(?P<version>[0-9.]+)$`,
+			tag:           "foo-bar-1.2.3",
+			expectedMatch: TagMatch{Plugin: "foo-bar", Version: "v1.2.3"},
+		},
+		{
+			name:          "pattern without captures",
+			pattern:       `^v[0-9.]+$`,
+			tag:           "v1.2.3",
+			expectedMatch: TagMatch{Version: "v1.2.3"},
+		},
+		{
+			name:          "tag does not match",
+			pattern:       `^kubectl-(?P<plugin>[a-z-]+)/(?P<version>.+)$`,
+			tag:           "v1.2.3",
+			expectedError: `tag "v1.2.3" does not match tag pattern "^kubectl-(?P<plugin>[a-z-]+)/(?P<version>.+)$"`,
+		},
+		{
+			name:          "invalid pattern",
+			pattern:       `^kubectl-(?P<plugin>[a-z-]+`,
+			tag:           "kubectl-foo/v1.2.3",
+			expectedError: "invalid tag pattern \"^kubectl-(?P<plugin>[a-z-]+\". error: error parsing regexp: missing closing ): `^kubectl-(?P<plugin>[a-z-]+`",
+		},
+		{
+			name:          "captured version is not semver",
+			pattern:       `^kubectl-(?P<plugin>[a-z-]+)/(?P<version>.+)$`,
+			tag:           "kubectl-foo/latest",
+			expectedError: `version "vlatest" for tag "latest" is not a valid semver. error: could not parse "vlatest" as version`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, err := MatchTag(tc.pattern, tc.tag)
+			assert.Equal(t, tc.expectedMatch, match)
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}
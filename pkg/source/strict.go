@@ -0,0 +1,92 @@
+package source
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/yaml"
+)
+
+//noValue is rendered by templates for missing values e.g. of a map, or of a nil interface
+const noValue = "<no value>"
+
+//placeholderTokens indicate a placeholder which was not rendered e.g. inside a string literal of the template
+var placeholderTokens = []string{"{{", "}}"}
+
+//freeTextFields are the fields whose text can have placeholders which are not meant to be rendered,
+//e.g. kubectl -o go-template='{{ .metadata.name }}' examples in the caveats
+var freeTextFields = map[string]bool{
+	"shortDescription": true,
+	"description":      true,
+	"caveats":          true,
+}
+
+//validateRenderedTemplate is used in strict mode to ensure that the rendered
+//template has no leftover placeholders, and is a valid plugin manifest without unknown fields
+func validateRenderedTemplate(name string, rendered []byte) error {
+	plugin := index.Plugin{}
+	err := yaml.UnmarshalStrict(rendered, &plugin)
+	if err != nil {
+		return fmt.Errorf("rendered template %s is not a valid plugin manifest. error: %v", name, err)
+	}
+
+	var manifest interface{}
+	err = yaml.Unmarshal(rendered, &manifest)
+	if err != nil {
+		return fmt.Errorf("rendered template %s is not a valid plugin manifest. error: %v", name, err)
+	}
+
+	problems := findUnrendered("", manifest, false)
+	if len(problems) > 0 {
+		return fmt.Errorf("rendered template %s has unrendered placeholders: %s", name, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+//findUnrendered walks the values of the manifest, and returns the fields with leftover placeholders
+func findUnrendered(field string, value interface{}, freeText bool) []string {
+	problems := []string{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			problems = append(problems, findUnrendered(joinField(field, key), v[key], freeText || freeTextFields[key])...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			problems = append(problems, findUnrendered(fmt.Sprintf("%s[%d]", field, i), item, freeText)...)
+		}
+	case string:
+		if strings.Contains(v, noValue) {
+			problems = append(problems, fmt.Sprintf("%s contains %q", field, noValue))
+		}
+
+		if freeText {
+			break
+		}
+
+		for _, token := range placeholderTokens {
+			if strings.Contains(v, token) {
+				problems = append(problems, fmt.Sprintf("%s contains %q", field, token))
+			}
+		}
+	}
+
+	return problems
+}
+
+func joinField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+
+	return parent + "." + key
+}
@@ -11,39 +11,74 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+//TemplateOptions are the options used when processing the .krew.yaml template
+type TemplateOptions struct {
+	//Workdir is where goreleaser config and artifacts are looked up,
+	//and the root for files read using readFile
+	Workdir string
+
+	//EnvAllowlist is the list of env variables that can be read using env
+	EnvAllowlist []string
+
+	//Strict fails on unrendered placeholders and unknown fields in the manifest. Fields missing
+	//in the template context, e.g. typos, fail with or without it
+	Strict bool
+
+	//Signatures verifies the signatures of the archives, if set
+	Signatures *SignatureVerifier
+
+	//ValidateURI validates the uri of an archive before it is downloaded, if set.
+	//Templates from untrusted sources must not make the server download arbitrary urls
+	ValidateURI func(uri string) error
+}
+
+//sha256ForAsset validates the uri, before downloading it to get its sha256
+func (opts TemplateOptions) sha256ForAsset(uri string) (string, error) {
+	if opts.ValidateURI != nil {
+		err := opts.ValidateURI(uri)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return getSha256ForAsset(uri, opts.Signatures)
+}
+
 //ProcessTemplate process the .krew.yaml template for the release request
-func ProcessTemplate(templateFile string, values interface{}) (string, []byte, error) {
+func ProcessTemplate(templateFile string, values interface{}, opts TemplateOptions) (string, []byte, error) {
 	name := path.Base(templateFile)
-	t := template.New(name).Funcs(map[string]interface{}{
-		"addURIAndSha": func(url, tag string) string {
-			t := struct {
-				TagName string
-			}{
-				TagName: tag,
-			}
-			buf := new(bytes.Buffer)
-			temp, err := template.New("url").Parse(url)
-			if err != nil {
-				panic(err)
-			}
-
-			err = temp.Execute(buf, t)
-			if err != nil {
-				panic(err)
-			}
-
-			logrus.Infof("getting sha256 for %s", buf.String())
-			sha256, err := getSha256ForAsset(buf.String())
-			if err != nil {
-				panic(err)
-			}
-
-			return fmt.Sprintf(`uri: %s
+	funcs := helperFuncs(opts)
+	funcs["addURIAndSha"] = func(url, tag string) string {
+		t := struct {
+			TagName string
+		}{
+			TagName: tag,
+		}
+		buf := new(bytes.Buffer)
+		temp, err := template.New("url").Parse(url)
+		if err != nil {
+			panic(err)
+		}
+
+		err = temp.Execute(buf, t)
+		if err != nil {
+			panic(err)
+		}
+
+		logrus.Infof("getting sha256 for %s", buf.String())
+		sha256, err := opts.sha256ForAsset(buf.String())
+		if err != nil {
+			panic(err)
+		}
+
+		return fmt.Sprintf(`uri: %s
     sha256: %s`, buf.String(), sha256)
-		},
-	})
+	}
+	funcs["addPlatformsFromGoreleaser"] = func(urlPrefix, tag string) (string, error) {
+		return addPlatformsFromGoreleaser(urlPrefix, tag, opts)
+	}
 
-	templateObject, err := t.ParseFiles(templateFile)
+	templateObject, err := template.New(name).Funcs(funcs).ParseFiles(templateFile)
 	if err != nil {
 		return "", nil, err
 	}
@@ -54,6 +89,13 @@ func ProcessTemplate(templateFile string, values interface{}) (string, []byte, e
 		return "", nil, err
 	}
 
+	if opts.Strict {
+		err = validateRenderedTemplate(name, buf.Bytes())
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
 	krewFile, err := ioutil.TempFile("", "krew-")
 	if err != nil {
 		return "", nil, err
@@ -0,0 +1,111 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessTemplateWithContext(t *testing.T) {
+	context := &TemplateContext{
+		ReleaseRequest: &ReleaseRequest{
+			TagName:     "v1.2.3",
+			PluginOwner: "rajatjindal",
+			PluginRepo:  "kubectl-whoami",
+		},
+		ReleaseName:     "whoami v1.2.3",
+		CommitSHA:       "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+		RepoDescription: "Show the subject that's currently authenticated as",
+		License:         "Apache-2.0",
+		SemVer:          SemVer{Major: 1, Minor: 2, Patch: 3},
+	}
+
+	expected := `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: whoami
+spec:
+  version: v1.2.3
+  homepage: https://github.com/rajatjindal/kubectl-whoami
+  shortDescription: Show the subject that's currently authenticated as
+  description: |
+    whoami v1.2.3 (1.2) built from 6dcb09b5b57875f334f61aebed695e2e4193db5e, licensed under Apache-2.0.
+`
+
+	pluginName, manifest, err := ProcessTemplate("data/context.krew.yaml", context, TemplateOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "whoami", pluginName)
+	assert.Equal(t, expected, string(manifest))
+}
+
+func TestProcessTemplateStrict(t *testing.T) {
+	testcases := []struct {
+		name          string
+		file          string
+		strict        bool
+		expectedError string
+	}{
+		{
+			name:   "valid template in strict mode",
+			file:   "data/strict/valid.krew.yaml",
+			strict: true,
+		},
+		{
+			name:          "missing field without strict mode",
+			file:          "data/strict/missing-key.krew.yaml",
+			expectedError: `template: missing-key.krew.yaml:6:14: executing "missing-key.krew.yaml" at <.TagNmae>: can't evaluate field TagNmae in type *source.TemplateContext`,
+		},
+		{
+			name:          "missing field in strict mode",
+			file:          "data/strict/missing-key.krew.yaml",
+			strict:        true,
+			expectedError: `template: missing-key.krew.yaml:6:14: executing "missing-key.krew.yaml" at <.TagNmae>: can't evaluate field TagNmae in type *source.TemplateContext`,
+		},
+		{
+			name: "unrendered placeholder without strict mode",
+			file: "data/strict/unrendered.krew.yaml",
+		},
+		{
+			name:          "unrendered placeholder in strict mode",
+			file:          "data/strict/unrendered.krew.yaml",
+			strict:        true,
+			expectedError: `rendered template unrendered.krew.yaml has unrendered placeholders: spec.homepage contains "{{", spec.homepage contains "}}"`,
+		},
+		{
+			name:          "no value in strict mode",
+			file:          "data/strict/no-value.krew.yaml",
+			strict:        true,
+			expectedError: `rendered template no-value.krew.yaml has unrendered placeholders: spec.homepage contains "<no value>"`,
+		},
+		{
+			name:   "go-template example in caveats in strict mode",
+			file:   "data/strict/go-template-caveats.krew.yaml",
+			strict: true,
+		},
+		{
+			name: "unknown field without strict mode",
+			file: "data/strict/unknown-field.krew.yaml",
+		},
+		{
+			name:          "unknown field in strict mode",
+			file:          "data/strict/unknown-field.krew.yaml",
+			strict:        true,
+			expectedError: `rendered template unknown-field.krew.yaml is not a valid plugin manifest. error: error unmarshaling JSON: while decoding JSON: json: unknown field "caveat"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			context := &TemplateContext{
+				ReleaseRequest: &ReleaseRequest{
+					TagName:     "v1.2.3",
+					PluginOwner: "rajatjindal",
+					PluginRepo:  "kubectl-whoami",
+				},
+			}
+
+			_, _, err := ProcessTemplate(tc.file, context, TemplateOptions{Strict: tc.strict})
+			assertError(t, tc.expectedError, err)
+		})
+	}
+}